@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io"
+	"time"
+
+	"currency/currency"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// options are the settings of an App, run sets them from the flags.
+type options struct {
+	// provider is the source of the rates.
+	provider Provider
+	// base is the lower-case code of the currency the rates are quoted
+	// against, the provider base when empty.
+	base string
+	// rows controls the columns of the rate rows.
+	rows rowOptions
+	// fallback walks back to the nearest day with published rates.
+	fallback bool
+	// offline reads the cache only, however old the cached days are.
+	offline bool
+	// cacheWrites stores the fetched days in the cache.
+	cacheWrites bool
+	// cacheTTL is how long today's cached rates stay fresh.
+	cacheTTL time.Duration
+	// failFast stops at the first currency that fails.
+	failFast bool
+	// allCodes emits every currency published for a date.
+	allCodes bool
+	// strict fails on duplicate codes and implausible rates instead of
+	// warning about them.
+	strict bool
+	// colorize colors the TSV change columns.
+	colorize bool
+	// prettyJSON indents the JSON output.
+	prettyJSON bool
+	// explain receives the --explain traces when set.
+	explain io.Writer
+	// aliases are the --currency-map symbols of currency codes.
+	aliases map[string]string
+}
+
+// defaultOptions are the options of the command line without flags.
+func defaultOptions() options {
+	return options{
+		provider:    cbrProvider{},
+		rows:        rowOptions{precision: defaultPrecision, roundMode: roundHalfEven, codeCase: codeCaseUpper},
+		fallback:    true,
+		cacheWrites: true,
+		cacheTTL:    defaultCacheTTL,
+		failFast:    true,
+	}
+}
+
+// App holds the state the rates are read through: the options, the cache
+// file, the client of the provider requests and the days of rates in
+// memory. The methods are safe for concurrent use. Apart from the logger,
+// the clock and the metrics, instances share no state, so several can run
+// side by side with separate caches and options.
+type App struct {
+	db     *bolt.DB
+	client *currency.Client
+	mem    *memoryRates
+	// published are the days of the emitted rows
+	published *publishedRates
+	ready     readiness
+	opts      options
+}
+
+func newApp(db *bolt.DB, client *currency.Client, mem *memoryRates, opts options) *App {
+	return &App{db: db, client: client, mem: mem, published: newPublishedRates(), opts: opts}
+}
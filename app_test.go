@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestAppsIndependent(t *testing.T) {
+	var first, second = newFakeCBR(t, testDate), newFakeCBR(t, testDate)
+	var a, b = first.app(t), second.app(t)
+	b.opts.rows.raw = true
+	b.opts.rows.withName = true
+
+	var rows = make([][]string, 2)
+	var errs = make([]error, 2)
+	var wg sync.WaitGroup
+	for i, app := range []*App{a, b} {
+		wg.Add(1)
+		go func(i int, app *App) {
+			defer wg.Done()
+			rows[i], errs[i] = app.getCurrencyItemCache(context.Background(), "jpy", testDate, false)
+		}(i, app)
+	}
+	wg.Wait()
+
+	for i, want := range [][]string{
+		{"14.10.2026", "JPY", "0.62", "RUB", "100"},
+		{"14.10.2026", "JPY", "62.3456", "RUB", "100", "Японских иен"},
+	} {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if !reflect.DeepEqual(rows[i], want) {
+			t.Errorf("app %d row = %v, want %v", i, rows[i], want)
+		}
+	}
+	if n, m := first.requests.Load(), second.requests.Load(); n != 1 || m != 1 {
+		t.Errorf("requests = %d and %d, want 1 to each provider", n, m)
+	}
+
+	// each cache holds the day its own App fetched, an App on another
+	// cache has nothing to read offline
+	for i, app := range []*App{a, b} {
+		app.opts.offline = true
+		_, err := reopen(app).getCurrencyItemCache(context.Background(), "usd", testDate, false)
+		if err != nil {
+			t.Errorf("app %d offline: %v", i, err)
+		}
+	}
+
+	var c = newTestApp(t)
+	c.opts.offline = true
+	_, err := c.getCurrencyItemCache(context.Background(), "usd", testDate, false)
+	var notCached *NotCachedError
+	if !errors.As(err, &notCached) {
+		t.Errorf("offline App on an empty cache error = %v, want NotCachedError", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// rateBand bounds the rates of the emitted rows, both ends inclusive.
+type rateBand struct {
+	min, max float64
+}
+
+// anyRate lets every rate through.
+var anyRate = rateBand{min: math.Inf(-1), max: math.Inf(1)}
+
+func newRateBand(min, max float64, hasMin, hasMax bool) (b rateBand, err error) {
+	b = anyRate
+	if hasMin {
+		b.min = min
+	}
+	if hasMax {
+		b.max = max
+	}
+
+	if math.IsNaN(b.min) || math.IsNaN(b.max) || b.min > b.max {
+		return b, fmt.Errorf("invalid rate band %v-%v, --min-rate must not exceed --max-rate", b.min, b.max)
+	}
+	return b, nil
+}
+
+func (b rateBand) bounded() bool {
+	return b != anyRate
+}
+
+func (b rateBand) contains(rate float64) bool {
+	return rate >= b.min && rate <= b.max
+}
+
+// filter keeps the rows whose rate per unit is within the band, rows
+// without a rate are dropped unless the band is unbounded. value looks up
+// the unrounded rate per unit by the code and date of the row, the rate
+// column may be rounded or per nominal.
+func (b rateBand) filter(rows [][]string, value func(code, date string) (float64, bool)) (kept [][]string) {
+	if !b.bounded() {
+		return rows
+	}
+
+	kept = rows[:0:0]
+	for _, row := range rows {
+		rate, ok := value(row[1], row[0])
+		if ok && b.contains(rate) {
+			kept = append(kept, row)
+		}
+	}
+	return
+}
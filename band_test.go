@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRateBandFilter(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	var tests = []struct {
+		name     string
+		min, max float64
+		raw      bool
+		want     []string
+	}{
+		{name: "unbounded", min: anyRate.min, max: anyRate.max, want: []string{"14.10.2026 USD", "14.10.2026 EUR", "14.10.2026 JPY"}},
+		{name: "between", min: 0.6, max: 93, want: []string{"14.10.2026 USD", "14.10.2026 JPY"}},
+		// JPY is 62.3456 per 100 and 0.623456 per unit
+		{name: "raw", min: 0.6, max: 93, raw: true, want: []string{"14.10.2026 USD", "14.10.2026 JPY"}},
+		// the rounded rate 0.62 is within, the published one is not
+		{name: "unrounded", min: 0.6, max: 0.62},
+		{name: "inclusive", min: 92.5012, max: 100.1234, want: []string{"14.10.2026 USD", "14.10.2026 EUR"}},
+	}
+
+	for _, tt := range tests {
+		var app = cbr.app(t)
+		app.opts.rows.raw = tt.raw
+
+		rows, err := app.collectRows(context.Background(), []time.Time{testDate}, []string{"usd", "eur", "jpy"}, false, false, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		band, err := newRateBand(tt.min, tt.max, true, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rowKeys(band.filter(rows, app.published.value)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: rows = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRateBandEvicted(t *testing.T) {
+	// a range longer than the memory cache
+	var dates = []time.Time{testDate.AddDate(0, 0, -1), testDate}
+	var cbr = newFakeCBR(t, dates...)
+	var app = cbr.app(t)
+	app = newApp(app.db, app.client, newMemoryRates(1), app.opts)
+
+	rows, err := app.collectRows(context.Background(), dates, []string{"usd"}, false, true, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	band, err := newRateBand(90, 95, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rowKeys(band.filter(rows, app.published.value)), []string{"13.10.2026 USD", "14.10.2026 USD"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+}
+
+func TestNewRateBand(t *testing.T) {
+	if _, err := newRateBand(2, 1, true, true); err == nil {
+		t.Error("--min-rate above --max-rate accepted")
+	}
+
+	band, err := newRateBand(0, 5, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !band.bounded() || !band.contains(-1) || !band.contains(5) || band.contains(5.01) {
+		t.Errorf("band up to 5 = %+v", band)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// readBatch returns a row for every "<code> <date>" line of r. Blank lines
+// are skipped; a malformed or failing line is reported with its number and
+// the remaining lines are still processed.
+func (a *App) readBatch(ctx context.Context, r io.Reader, skipCache bool) (rows [][]string, errs []error) {
+	var scanner = bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		var fields = strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		row, err := a.batchRow(ctx, fields, skipCache)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", n, err))
+			continue
+		}
+
+		rows = append(rows, row)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return
+}
+
+func (a *App) batchRow(ctx context.Context, fields []string, skipCache bool) (row []string, err error) {
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("expected '<code> <date>', got '%s'", strings.Join(fields, " "))
+	}
+
+	var code = strings.ToLower(a.opts.resolveAlias(fields[0]))
+	err = validateCode(code)
+	if err != nil {
+		return
+	}
+
+	t, err := parseDate(fields[1])
+	if err != nil {
+		return
+	}
+
+	row, day, err := a.currencyRow(ctx, code, t, skipCache)
+	if err != nil {
+		return
+	}
+
+	err = a.validateRate(code, day)
+	if err != nil {
+		return
+	}
+
+	a.published.add(day)
+	a.explainRate(code, t, day)
+
+	if a.opts.rows.withChange {
+		row = a.appendChange(ctx, row, code, t, skipCache)
+	}
+
+	return
+}
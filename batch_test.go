@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// batchInput has a blank line, a malformed line, an unknown currency and
+// an invalid date between valid lines.
+const batchInput = "usd 14.10.2026\n\neur\njpy 14.10.2026\nxyz 14.10.2026\nusd 32.10.2026\neur 14.10.2026\n"
+
+func TestReadBatch(t *testing.T) {
+	var app = newTestApp(t)
+	app.opts.provider = testProvider
+
+	rows, errs := app.readBatch(context.Background(), strings.NewReader(batchInput), false)
+
+	var want = [][]string{
+		{"14.10.2026", "USD", "92.50", "RUB", "1"},
+		{"14.10.2026", "JPY", "0.62", "RUB", "100"},
+		{"14.10.2026", "EUR", "100.12", "RUB", "1"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+
+	var lines = []string{"line 3: ", "line 5: ", "line 6: "}
+	if len(errs) != len(lines) {
+		t.Fatalf("errors = %v, want one for each of %v", errs, lines)
+	}
+	for i, prefix := range lines {
+		if !strings.HasPrefix(errs[i].Error(), prefix) {
+			t.Errorf("error %d = %v, want it to start with %q", i, errs[i], prefix)
+		}
+	}
+}
+
+func TestStdinFlag(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	var path = filepath.Join(t.TempDir(), "input")
+	err := os.WriteFile(path, []byte(batchInput), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	set(t, &os.Stdin, stdin)
+
+	// the valid lines are written and the run fails for the others
+	var code int
+	var out string
+	var stderr = capture(t, &os.Stderr, func() {
+		code, out = runCLI(t, "--url-template", cbr.template(), "--stdin")
+	})
+	if code != exitError {
+		t.Errorf("exit code = %d, want %d", code, exitError)
+	}
+	var want = "14.10.2026\tUSD\t92.50\tRUB\t1\n14.10.2026\tJPY\t0.62\tRUB\t100\n14.10.2026\tEUR\t100.12\tRUB\t1\n"
+	if out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+
+	// each failed line is logged, then the summary
+	for _, want := range []string{"line 3: ", "line 5: ", "line 6: ", "3 line(s) failed, 3 row(s) written"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("stderr = %q, want it to contain %q", stderr, want)
+		}
+	}
+}
@@ -0,0 +1,532 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"currency/currency"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	defaultCacheTTL  = 12 * time.Hour
+	negativeCacheTTL = time.Hour
+	cachePathEnv     = "CURRENCY_CACHE_PATH"
+
+	cacheBucket = "rates"
+	// cacheVersion is the format of the cached days, entries of other
+	// versions are ignored
+	cacheVersion = 2
+
+	defaultCacheDirMode  = "0700"
+	defaultCacheFileMode = "0600"
+)
+
+// cachedDay is the cached value for a requested date. Date is the date the
+// rates were actually published for, Absent marks a date known to have no
+// rates published.
+type cachedDay struct {
+	Version  int          `json:"version"`
+	Date     time.Time    `json:"date"`
+	CachedAt time.Time    `json:"cached_at"`
+	Rates    []cachedRate `json:"rates"`
+	Absent   bool         `json:"absent,omitempty"`
+	// HTTP validators of the response the rates were decoded from
+	URL          string `json:"url,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// cachedRate is a published rate, parsed. It is formatted only when the
+// rows are built, so the cache does not depend on the output options.
+type cachedRate struct {
+	ID      string  `json:"id,omitempty"`
+	NumCode int64   `json:"num_code,omitempty"`
+	Code    string  `json:"code"`
+	Name    string  `json:"name"`
+	Nominal int64   `json:"nominal"`
+	Value   float64 `json:"value"`
+}
+
+func newCachedRates(valutes []*Valute) (rates []cachedRate, err error) {
+	for _, v := range valutes {
+		value, err := v.getRawValue()
+		if err != nil {
+			return nil, err
+		}
+
+		rates = append(rates, cachedRate{
+			ID:      v.ID,
+			NumCode: v.NumCode,
+			Code:    v.CharCode,
+			Name:    v.Name,
+			Nominal: v.Nominal,
+			Value:   value,
+		})
+	}
+	return
+}
+
+func (d cachedDay) valutes() (valutes []*Valute) {
+	for _, r := range d.Rates {
+		valutes = append(valutes, &Valute{
+			ID:       r.ID,
+			NumCode:  r.NumCode,
+			CharCode: r.Code,
+			Name:     r.Name,
+			Nominal:  r.Nominal,
+			Value:    strconv.FormatFloat(r.Value, 'f', -1, 64),
+		})
+	}
+	return
+}
+
+// expired reports whether the day cached for the requested date t is too
+// old to be used. Rates for past dates never change, so a past entry is
+// final once it holds the rates of the date itself or was cached after the
+// date was over. A fallback cached during the day, before the rates of the
+// date were published, expires as today's entries do. Absent dates expire
+// after negativeCacheTTL as the rates may still be published.
+func (d cachedDay) expired(t time.Time, ttl time.Duration) bool {
+	if d.Absent {
+		return now().Sub(d.CachedAt) > negativeCacheTTL
+	}
+
+	var day = truncateDay(t)
+	if day.Before(truncateDay(now())) {
+		if ratesKey(d.Date) == ratesKey(t) || !d.CachedAt.Before(day.AddDate(0, 0, 1)) {
+			return false
+		}
+	}
+
+	return now().Sub(d.CachedAt) > ttl
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// defaultCachePath is the cache file under $HOME/.cache. Without HOME, as
+// in many containers, it falls back to the user cache directory and then
+// to the temporary directory rather than a path under /.
+func defaultCachePath() string {
+	if home := os.Getenv("HOME"); home != "" {
+		return filepath.Join(home, ".cache", "currency", "cache")
+	}
+
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "currency", "cache")
+	}
+
+	return filepath.Join(os.TempDir(), "currency", "cache")
+}
+
+// resolveCachePath picks the cache file: flag value if set, then the
+// environment variable, then the default location.
+func resolveCachePath(flagValue string, env string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+
+	if env != "" {
+		return env
+	}
+
+	return defaultCachePath()
+}
+
+// legacyCacheBuckets were written by older versions: per-currency rows in
+// "cache" and whole days without the provider in the key in "days".
+var legacyCacheBuckets = []string{"cache", "days"}
+
+// parseMode parses an octal permission mode such as 0700.
+func parseMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid mode '%s', expected octal permissions like 0700", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// migrateCache drops the entries written by older versions.
+func (a *App) migrateCache() error {
+	return a.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range legacyCacheBuckets {
+			if tx.Bucket([]byte(name)) == nil {
+				continue
+			}
+
+			err := tx.DeleteBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// dayCacheKey is the cache key of the rates of the provider for t.
+func (a *App) dayCacheKey(t time.Time) string {
+	return fmt.Sprintf("%s-%s", a.opts.provider.Name(), t.Format(outputDateFormat))
+}
+
+// historyCacheKey is the cache key of the rate of a single currency for t
+// loaded from its rate history.
+func (a *App) historyCacheKey(code string, t time.Time) string {
+	return fmt.Sprintf("%s-%s-%s", a.opts.provider.Name(), strings.ToLower(code), t.Format(outputDateFormat))
+}
+
+// readCachedDay looks the day up in the cache, ok is false on a miss. It
+// only reads, so cache hits do not take the write lock; the bucket is
+// created by writeCachedDay.
+func (a *App) readCachedDay(t time.Time) (day cachedDay, ok bool, err error) {
+	return a.readCacheKey(a.dayCacheKey(t))
+}
+
+// readCacheKey looks the day cached under cacheKey up, as readCachedDay.
+func (a *App) readCacheKey(cacheKey string) (day cachedDay, ok bool, err error) {
+	err = a.db.View(func(tx *bolt.Tx) error {
+		var b = tx.Bucket([]byte(cacheBucket))
+		if b == nil {
+			return nil
+		}
+
+		// the value is only valid within the transaction
+		var val = b.Get([]byte(cacheKey))
+		if val == nil {
+			return nil
+		}
+
+		ok = true
+		return json.Unmarshal(val, &day)
+	})
+	if err != nil || !ok {
+		return cachedDay{}, false, err
+	}
+
+	if day.Version != cacheVersion {
+		logger.Debugf("ignoring cache entry for %s of format version %d", cacheKey, day.Version)
+		return cachedDay{}, false, nil
+	}
+
+	// empty days were once cached under --no-fallback, they are refetched
+	if !day.Absent && len(day.Rates) == 0 {
+		logger.Debugf("ignoring empty cache entry for %s", cacheKey)
+		return cachedDay{}, false, nil
+	}
+
+	return day, true, nil
+}
+
+// writeCachedDay stores the day in a single read-write transaction, which
+// bbolt either commits or rolls back, creating the bucket when missing.
+// With --no-cache-write nothing is stored.
+func (a *App) writeCachedDay(t time.Time, day cachedDay) error {
+	return a.writeCacheKey(a.dayCacheKey(t), t, day)
+}
+
+// writeCacheKey stores the day requested for t under cacheKey, as
+// writeCachedDay.
+func (a *App) writeCacheKey(cacheKey string, t time.Time, day cachedDay) error {
+	if !a.opts.cacheWrites {
+		logger.with("date", ratesKey(t)).Debugf("not caching %s, cache writes are disabled", cacheKey)
+		return nil
+	}
+
+	val, err := json.Marshal(day)
+	if err != nil {
+		return err
+	}
+
+	return a.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(cacheBucket))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(cacheKey), val)
+	})
+}
+
+// loadDayCache loads the whole day of rates for t into memory, from the
+// cache when possible and from the provider otherwise, and returns it. hit
+// reports whether the cache was used. The provider is queried outside of
+// any cache transaction so that concurrent loads of different days do not
+// wait on each other.
+func (a *App) loadDayCache(ctx context.Context, t time.Time, skipCache bool) (loaded *dayRates, hit bool, err error) {
+	var stale cachedDay
+	if !skipCache {
+		day, ok, err := a.readCachedDay(t)
+		if err != nil {
+			return nil, false, &CacheError{Err: err}
+		}
+
+		// offline the cached day is used however old it is
+		if ok && (a.opts.offline || !day.expired(t, a.opts.cacheTTL)) {
+			loaded, err = a.loadCachedDay(t, day, fromCache)
+			return loaded, true, err
+		}
+
+		if ok {
+			logger.with("date", ratesKey(t)).Debugf("cache entry for %s expired", a.dayCacheKey(t))
+			stale = day
+		}
+	}
+
+	logger.with("date", ratesKey(t)).Debugf("cache miss for %s", a.dayCacheKey(t))
+
+	// an expired day is refetched with a conditional request
+	var cond = currency.Validators{URL: stale.URL, ETag: stale.ETag, LastModified: stale.LastModified}
+	valutes, date, v, err := a.fetchRates(ctx, t, cond)
+	if errors.Is(err, currency.ErrNotModified) {
+		logger.with("url", stale.URL).Debugf("%s not modified, keeping the cached rates", stale.URL)
+		stale.CachedAt = now()
+		werr := a.writeCachedDay(t, stale)
+		if werr != nil {
+			return nil, false, &CacheError{Err: werr}
+		}
+		loaded, err = a.loadCachedDay(t, stale, fromRevalidated)
+		return loaded, true, err
+	}
+
+	var notPublished *currency.NotPublishedError
+	var day = cachedDay{Version: cacheVersion, Date: date, CachedAt: now()}
+	switch {
+	case errors.As(err, &notPublished):
+		day.Date, day.Absent = t, true
+	case err != nil:
+		return
+	default:
+		day.Rates, err = newCachedRates(valutes)
+		if err != nil {
+			return
+		}
+		day.URL, day.ETag, day.LastModified = v.URL, v.ETag, v.LastModified
+
+		// only days that load are cached
+		loaded, err = a.loadRates(t, valutes, date, origin{from: fromProvider, url: v.URL, at: now()})
+		if err != nil {
+			return
+		}
+
+		// an empty day is only returned without the fallback, which would
+		// have found a published day, so it is not cached
+		if len(valutes) == 0 {
+			logger.with("date", ratesKey(t)).Debugf("not caching %s, no rates were published", a.dayCacheKey(t))
+			return loaded, false, nil
+		}
+	}
+
+	// the single cache write, the not published error is still returned
+	werr := a.writeCachedDay(t, day)
+	if werr != nil {
+		return nil, false, &CacheError{Err: werr}
+	}
+	return loaded, false, err
+}
+
+// loadCachedDay fills the in-memory rates for t from a cached day.
+func (a *App) loadCachedDay(t time.Time, day cachedDay, from string) (*dayRates, error) {
+	logger.with("date", ratesKey(t)).Debugf("cache hit for %s", a.dayCacheKey(t))
+	if day.Absent {
+		return nil, &currency.NotPublishedError{Date: t, Days: maxFallbackDays}
+	}
+	return a.loadRates(t, day.valutes(), day.Date, origin{from: from, url: day.URL, at: day.CachedAt})
+}
+
+// memDay returns the day for t from memory. As in the cache file, today's
+// rates expire after the cache TTL unless offline.
+func (a *App) memDay(t time.Time) (*dayRates, bool) {
+	var ttl = a.opts.cacheTTL
+	if a.opts.offline {
+		ttl = math.MaxInt64
+	}
+	return a.mem.get(t, ttl)
+}
+
+// dayRatesCache returns the rates for t from memory, loading the day from
+// the cache or the provider when it is not there.
+func (a *App) dayRatesCache(ctx context.Context, t time.Time, skipCache bool) (day *dayRates, err error) {
+	var hit = true
+	day, ok := a.memDay(t)
+	if !ok {
+		day, hit, err = a.loadDayCache(ctx, t, skipCache)
+		if err != nil {
+			return
+		}
+	}
+
+	if hit {
+		cacheLookups.WithLabelValues(cacheHit).Inc()
+	} else {
+		cacheLookups.WithLabelValues(cacheMiss).Inc()
+	}
+	return
+}
+
+func (a *App) getCurrencyItemCache(ctx context.Context, name string, t time.Time, skipCache bool) (r []string, err error) {
+	r, _, err = a.currencyRow(ctx, name, t, skipCache)
+	return
+}
+
+// currencyRow returns the row of the currency for t along with the day it
+// was built from.
+func (a *App) currencyRow(ctx context.Context, name string, t time.Time, skipCache bool) (r []string, day *dayRates, err error) {
+	day, err = a.dayRatesCache(ctx, t, skipCache)
+	if err != nil {
+		return
+	}
+
+	r, err = day.row(name, t)
+	return
+}
+
+// clearCache removes all cached days and returns how many were removed.
+func (a *App) clearCache() (n int, err error) {
+	err = a.db.Update(func(tx *bolt.Tx) error {
+		var b = tx.Bucket([]byte(cacheBucket))
+		if b == nil {
+			return nil
+		}
+
+		n = b.Stats().KeyN
+		return tx.DeleteBucket([]byte(cacheBucket))
+	})
+	return
+}
+
+var cacheStatsHeader = []string{"provider", "entries", "absent", "first_date", "last_date", "bytes"}
+
+// cacheStats returns a row per provider with the number of cached days,
+// how many of them are known to have no rates, the requested dates they
+// cover and the approximate size of the keys and values.
+func (a *App) cacheStats() (rows [][]string, err error) {
+	type stats struct {
+		entries, absent, bytes int
+		first, last            time.Time
+	}
+
+	var providers []string
+	var byProvider = map[string]*stats{}
+	err = a.db.View(func(tx *bolt.Tx) error {
+		var b = tx.Bucket([]byte(cacheBucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			var key = string(k)
+			var i = strings.LastIndex(key, "-")
+			if i < 0 {
+				return fmt.Errorf("malformed cache key '%s'", key)
+			}
+
+			t, err := time.Parse(outputDateFormat, key[i+1:])
+			if err != nil {
+				return fmt.Errorf("malformed cache key '%s'", key)
+			}
+
+			s, ok := byProvider[key[:i]]
+			if !ok {
+				s = &stats{first: t, last: t}
+				byProvider[key[:i]] = s
+				providers = append(providers, key[:i])
+			}
+
+			var day cachedDay
+			if json.Unmarshal(v, &day) == nil && day.Absent {
+				s.absent++
+			}
+			s.entries++
+			s.bytes += len(k) + len(v)
+			if t.Before(s.first) {
+				s.first = t
+			}
+			if t.After(s.last) {
+				s.last = t
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return
+	}
+
+	sort.Strings(providers)
+	for _, name := range providers {
+		s := byProvider[name]
+		rows = append(rows, []string{
+			name,
+			strconv.Itoa(s.entries),
+			strconv.Itoa(s.absent),
+			s.first.Format(outputDateFormat),
+			s.last.Format(outputDateFormat),
+			strconv.Itoa(s.bytes),
+		})
+	}
+	return
+}
+
+// cacheEntry is a cached day dumped with its key.
+type cacheEntry struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// dumpCache writes every cached key and value as a JSON array in key
+// order.
+func (a *App) dumpCache(w io.Writer) error {
+	var entries = []cacheEntry{}
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var b = tx.Bucket([]byte(cacheBucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			// the value is only valid within the transaction
+			var value = append(json.RawMessage(nil), v...)
+			if !json.Valid(value) {
+				return fmt.Errorf("malformed cache entry '%s'", k)
+			}
+			entries = append(entries, cacheEntry{Key: string(k), Value: value})
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	var enc = json.NewEncoder(w)
+	if a.opts.prettyJSON {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(entries)
+}
+
+// getCurrencyValueCache returns the rate per unit of the currency for t,
+// the provider base included, loading the day from the cache when
+// possible.
+func (a *App) getCurrencyValueCache(ctx context.Context, name string, t time.Time, skipCache bool) (val float64, err error) {
+	day, err := a.dayRatesCache(ctx, t, skipCache)
+	if err != nil {
+		return
+	}
+
+	if val, ok := day.values[day.code(name)]; ok {
+		return val, nil
+	}
+
+	err = &CurrencyNotFoundError{Code: name, Date: t}
+	return
+}
@@ -0,0 +1,789 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"currency/currency"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// reopen returns an App on the cache of app with empty memory, as a later
+// invocation would see it.
+func reopen(app *App) *App {
+	return newApp(app.db, app.client, newMemoryRates(defaultMemCacheSize), app.opts)
+}
+
+func TestDayCacheSharedByCurrencies(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+	var app = cbr.app(t)
+
+	_, err := app.getCurrencyItemCache(context.Background(), "usd", testDate, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cbr.requests.Store(0)
+	row, err := reopen(app).getCurrencyItemCache(context.Background(), "eur", testDate, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row[1] != "EUR" {
+		t.Errorf("row = %v, want EUR", row)
+	}
+	if n := cbr.requests.Load(); n != 0 {
+		t.Errorf("requests for another currency of a cached day = %d, want 0", n)
+	}
+}
+
+func TestEmptyDayNotCached(t *testing.T) {
+	var previous = testDate.AddDate(0, 0, -1)
+	var cbr = newFakeCBR(t, previous)
+	var app = cbr.app(t)
+
+	app.opts.fallback = false
+	_, err := app.getCurrencyItemCache(context.Background(), "usd", testDate, false)
+	var notFound *CurrencyNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("no-fallback error = %v, want CurrencyNotFoundError", err)
+	}
+
+	app.opts.fallback = true
+	row, err := reopen(app).getCurrencyItemCache(context.Background(), "usd", testDate, false)
+	if err != nil {
+		t.Fatalf("fallback after a no-fallback run: %v", err)
+	}
+	if row[0] != ratesKey(previous) {
+		t.Errorf("date = %s, want the previous day %s", row[0], ratesKey(previous))
+	}
+}
+
+func TestMigrateCacheDropsLegacyBuckets(t *testing.T) {
+	var app = newTestApp(t)
+	err := app.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range append(legacyCacheBuckets, cacheBucket) {
+			b, err := tx.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			err = b.Put([]byte("usd-14.10.2026"), []byte("92.50"))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.migrateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.db.View(func(tx *bolt.Tx) error {
+		for _, name := range legacyCacheBuckets {
+			if tx.Bucket([]byte(name)) != nil {
+				t.Errorf("legacy bucket %s kept", name)
+			}
+		}
+		if tx.Bucket([]byte(cacheBucket)) == nil {
+			t.Errorf("bucket %s dropped", cacheBucket)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// clock returns a now func at the hour of testDate that advance moves.
+func clock(t *testing.T, hour int) (advance func(time.Duration)) {
+	var current = testDate.Add(time.Duration(hour) * time.Hour)
+	set(t, &now, func() time.Time { return current })
+	return func(d time.Duration) { current = current.Add(d) }
+}
+
+func TestCachedDayExpired(t *testing.T) {
+	clock(t, 20)
+
+	var yesterday = testDate.AddDate(0, 0, -1)
+	var tests = []struct {
+		name    string
+		day     cachedDay
+		t       time.Time
+		expired bool
+	}{
+		{name: "today within the ttl", day: cachedDay{CachedAt: testDate.Add(9 * time.Hour)}, t: testDate},
+		{name: "today past the ttl", day: cachedDay{CachedAt: testDate.Add(7 * time.Hour)}, t: testDate, expired: true},
+		{name: "past date past the ttl", day: cachedDay{Date: yesterday, CachedAt: yesterday.Add(8 * time.Hour)}, t: yesterday},
+		// a fallback cached before the rates of the date were published
+		{name: "past fallback cached that day", day: cachedDay{Date: yesterday.AddDate(0, 0, -1), CachedAt: yesterday.Add(8 * time.Hour)}, t: yesterday, expired: true},
+		{name: "past fallback cached after the date", day: cachedDay{Date: yesterday.AddDate(0, 0, -1), CachedAt: testDate.Add(time.Hour)}, t: yesterday},
+		{name: "absent within the negative ttl", day: cachedDay{Absent: true, CachedAt: testDate.Add(19*time.Hour + 30*time.Minute)}, t: yesterday},
+		{name: "absent past the negative ttl", day: cachedDay{Absent: true, CachedAt: testDate.Add(18 * time.Hour)}, t: yesterday, expired: true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.day.expired(tt.t, 12*time.Hour); got != tt.expired {
+			t.Errorf("%s: expired = %v, want %v", tt.name, got, tt.expired)
+		}
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	var yesterday = testDate.AddDate(0, 0, -1)
+	var cbr = newFakeCBR(t, testDate, yesterday)
+	var advance = clock(t, 8)
+
+	var app = cbr.app(t)
+	app.opts.cacheTTL = 12 * time.Hour
+	for _, d := range []time.Time{testDate, yesterday} {
+		_, err := app.getCurrencyItemCache(context.Background(), "usd", d, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var requests = func(d time.Time) int32 {
+		cbr.requests.Store(0)
+		_, err := reopen(app).getCurrencyItemCache(context.Background(), "usd", d, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cbr.requests.Load()
+	}
+
+	advance(11 * time.Hour)
+	if n := requests(testDate); n != 0 {
+		t.Errorf("requests for today within the ttl = %d, want 0", n)
+	}
+
+	advance(2 * time.Hour)
+	if n := requests(testDate); n != 1 {
+		t.Errorf("requests for today past the ttl = %d, want 1", n)
+	}
+	if n := requests(yesterday); n != 0 {
+		t.Errorf("requests for a past date = %d, want 0", n)
+	}
+}
+
+func TestCachedFallbackTTL(t *testing.T) {
+	var yesterday = testDate.AddDate(0, 0, -1)
+	var dayBefore = yesterday.AddDate(0, 0, -1)
+	var cbr = newFakeCBR(t, dayBefore)
+	// yesterday morning, before its rates are published
+	var advance = clock(t, -16)
+
+	var app = cbr.app(t)
+	app.opts.cacheTTL = 12 * time.Hour
+	var date = func(app *App) string {
+		t.Helper()
+		cbr.requests.Store(0)
+		row, err := app.getCurrencyItemCache(context.Background(), "usd", yesterday, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return row[0]
+	}
+	if got := date(app); got != ratesKey(dayBefore) {
+		t.Fatalf("date = %s, want the fallback %s", got, ratesKey(dayBefore))
+	}
+
+	// the fallback expires in memory and in the cache file once the date
+	// is past
+	cbr.published[yesterday.Format(xmlDateFormat)] = true
+	advance(28 * time.Hour)
+	if got := date(app); got != ratesKey(yesterday) {
+		t.Errorf("date past the ttl = %s, want %s", got, ratesKey(yesterday))
+	}
+	if n := cbr.requests.Load(); n != 1 {
+		t.Errorf("requests past the ttl = %d, want 1", n)
+	}
+
+	// the published rates of the date are final
+	advance(48 * time.Hour)
+	if got := date(reopen(app)); got != ratesKey(yesterday) {
+		t.Errorf("date = %s, want %s", got, ratesKey(yesterday))
+	}
+	if n := cbr.requests.Load(); n != 0 {
+		t.Errorf("requests for the published date = %d, want 0", n)
+	}
+}
+
+func TestResolveCachePath(t *testing.T) {
+	var home = t.TempDir()
+	t.Setenv("HOME", home)
+
+	var tests = []struct {
+		flag, env, want string
+	}{
+		{flag: "/tmp/flag", env: "/tmp/env", want: "/tmp/flag"},
+		{env: "/tmp/env", want: "/tmp/env"},
+		{want: filepath.Join(home, ".cache", "currency", "cache")},
+	}
+
+	for _, tt := range tests {
+		if got := resolveCachePath(tt.flag, tt.env); got != tt.want {
+			t.Errorf("resolveCachePath(%q, %q) = %s, want %s", tt.flag, tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultCachePathWithoutHome(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the user cache directory comes from XDG_CACHE_HOME on Linux only")
+	}
+
+	var xdg, tmp = t.TempDir(), t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	var tests = []struct {
+		name, xdg, want string
+	}{
+		{name: "user cache directory", xdg: xdg, want: filepath.Join(xdg, "currency", "cache")},
+		{name: "temporary directory", want: filepath.Join(tmp, "currency", "cache")},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("HOME", "")
+		t.Setenv("XDG_CACHE_HOME", tt.xdg)
+
+		var path = defaultCachePath()
+		if path != tt.want {
+			t.Errorf("%s: path = %s, want %s", tt.name, path, tt.want)
+			continue
+		}
+
+		// the cache file can be created there
+		var cbr = newFakeCBR(t, testDate)
+		if code, _ := runCLI(t, "--cache-path", path, "--url-template", cbr.template(), "--date", "14.10.2026"); code != exitOK {
+			t.Errorf("%s: exit code = %d, want %d", tt.name, code, exitOK)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+func TestClearCache(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate, testDate.AddDate(0, 0, -1))
+
+	var app = cbr.app(t)
+	for _, d := range []time.Time{testDate, testDate.AddDate(0, 0, -1)} {
+		_, err := app.getCurrencyItemCache(context.Background(), "usd", d, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, want := range []int{2, 0} {
+		n, err := app.clearCache()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Errorf("cleared %d entries, want %d", n, want)
+		}
+	}
+}
+
+func TestCacheClearCommand(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate, testDate.AddDate(0, 0, -1))
+	var path = filepath.Join(t.TempDir(), "rates.db")
+
+	code, _ := runCLI(t, "--cache-path", path, "--url-template", cbr.template(), "--from-date", "13.10.2026", "--to-date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("exit code = %d", code)
+	}
+
+	code, out := runCLI(t, "--cache-path", path, "cache", "clear")
+	if code != exitOK {
+		t.Fatalf("cache clear exit code = %d", code)
+	}
+	if want := "removed 2 cached entries from " + path + "\n"; out != want {
+		t.Errorf("cache clear = %q, want %q", out, want)
+	}
+}
+
+func TestOffline(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+	var app = cbr.app(t)
+	_, err := app.getCurrencyItemCache(context.Background(), "usd", testDate, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.opts.offline = true
+	cbr.requests.Store(0)
+	var ctx = context.Background()
+
+	_, err = reopen(app).getCurrencyItemCache(ctx, "eur", testDate, false)
+	if err != nil {
+		t.Errorf("offline rate: %v", err)
+	}
+	_, err = reopen(app).convert(ctx, "usd", "eur", 1, testDate, false)
+	if err != nil {
+		t.Errorf("offline conversion: %v", err)
+	}
+	_, err = reopen(app).listCurrencies(ctx, testDate, sortByCode, anyRate, false)
+	if err != nil {
+		t.Errorf("offline list: %v", err)
+	}
+	if n := cbr.requests.Load(); n != 0 {
+		t.Errorf("offline requests = %d, want 0", n)
+	}
+
+	_, err = reopen(app).getCurrencyItemCache(ctx, "usd", testDate.AddDate(0, 0, -1), false)
+	var notCached *NotCachedError
+	if !errors.As(err, &notCached) || exitCode(err) != exitCache {
+		t.Errorf("offline miss error = %v, want NotCachedError", err)
+	}
+}
+
+func TestOfflineCommands(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+	var path = filepath.Join(t.TempDir(), "rates.db")
+
+	code, _ := runCLI(t, "--cache-path", path, "--url-template", cbr.template(), "--date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("exit code = %d", code)
+	}
+	cbr.requests.Store(0)
+
+	var tests = []struct {
+		args []string
+		code int
+		out  string
+	}{
+		{args: []string{"--from", "usd", "--to", "eur"}, code: exitOK, out: "0.92\n"},
+		{args: []string{"list"}, code: exitOK, out: "EUR\tЕвро\t1\nJPY\tЯпонских иен\t100\nUSD\tДоллар США\t1\n"},
+		{args: []string{"--currency", "jpy"}, code: exitOK, out: "14.10.2026\tJPY\t0.62\tRUB\t100\n"},
+		{args: []string{"--date", "13.10.2026"}, code: exitCache},
+		{args: []string{"--skip-cache"}, code: exitUsage},
+	}
+
+	for _, tt := range tests {
+		var args = append([]string{"--cache-path", path, "--url-template", cbr.template(), "--offline", "--date", "14.10.2026"}, tt.args...)
+		code, out := runCLI(t, args...)
+		if code != tt.code || out != tt.out {
+			t.Errorf("%v: exit code %d, output %q, want %d, %q", tt.args, code, out, tt.code, tt.out)
+		}
+	}
+
+	if n := cbr.requests.Load(); n != 0 {
+		t.Errorf("offline requests = %d, want 0", n)
+	}
+}
+
+func TestConditionalRefetch(t *testing.T) {
+	body, err := os.ReadFile("testdata/daily.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the rates never change, a conditional request is answered with 304
+	var requests, notModified atomic.Int32
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` && r.Header.Get("If-Modified-Since") == "Wed, 14 Oct 2026 09:30:00 GMT" {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=windows-1251")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Wed, 14 Oct 2026 09:30:00 GMT")
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	var advance = clock(t, 8)
+
+	var app = newTestApp(t)
+	app.client = newTestClient(srv.Client(), srv.URL+"/?date_req=%s")
+	app.opts.cacheTTL = 12 * time.Hour
+	_, err = app.getCurrencyItemCache(context.Background(), "usd", testDate, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// past the ttl the cached rates are revalidated, not downloaded again
+	advance(13 * time.Hour)
+	row, err := reopen(app).getCurrencyItemCache(context.Background(), "eur", testDate, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"14.10.2026", "EUR", "100.12", "RUB", "1"}; !reflect.DeepEqual(row, want) {
+		t.Errorf("revalidated row = %v, want %v", row, want)
+	}
+	if n, m := requests.Load(), notModified.Load(); n != 2 || m != 1 {
+		t.Errorf("requests = %d with %d not modified, want 2 with 1", n, m)
+	}
+
+	// the revalidation restarts the ttl
+	advance(time.Hour)
+	_, err = reopen(app).getCurrencyItemCache(context.Background(), "jpy", testDate, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("requests after the revalidation = %d, want 2", n)
+	}
+}
+
+func TestNegativeCache(t *testing.T) {
+	var cbr = newFakeCBR(t)
+	var advance = clock(t, 8)
+	var app = cbr.app(t)
+
+	var lookup = func(name string) int32 {
+		cbr.requests.Store(0)
+		_, err := reopen(app).getCurrencyItemCache(context.Background(), "usd", testDate, false)
+		var notPublished *currency.NotPublishedError
+		if !errors.As(err, &notPublished) {
+			t.Errorf("%s: error = %v, want NotPublishedError", name, err)
+		}
+		return cbr.requests.Load()
+	}
+
+	if n := lookup("first lookup"); n == 0 {
+		t.Fatal("first lookup made no requests")
+	}
+	if n := lookup("second lookup"); n != 0 {
+		t.Errorf("requests for a date cached as absent = %d, want 0", n)
+	}
+
+	advance(negativeCacheTTL + time.Minute)
+	if n := lookup("lookup past the negative ttl"); n == 0 {
+		t.Error("lookup past the negative ttl made no requests")
+	}
+
+	// an unknown currency of a published day is answered by the cached day
+	cbr = newFakeCBR(t, testDate)
+	app = cbr.app(t)
+	for i := 0; i < 2; i++ {
+		cbr.requests.Store(0)
+		_, err := reopen(app).getCurrencyItemCache(context.Background(), "xyz", testDate, false)
+		var notFound *CurrencyNotFoundError
+		if !errors.As(err, &notFound) {
+			t.Errorf("lookup %d of an unknown currency error = %v, want CurrencyNotFoundError", i, err)
+		}
+		if n, want := cbr.requests.Load(), int32(1-i); n != want {
+			t.Errorf("lookup %d of an unknown currency requests = %d, want %d", i, n, want)
+		}
+	}
+}
+
+func TestCacheModes(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	var tests = []struct {
+		args      []string
+		dir, file os.FileMode
+	}{
+		{dir: 0700, file: 0600},
+		{args: []string{"--cache-dir-mode", "0750", "--cache-file-mode", "0640"}, dir: 0750, file: 0640},
+	}
+
+	for _, tt := range tests {
+		var dir = filepath.Join(t.TempDir(), "currency")
+		var path = filepath.Join(dir, "cache")
+		var args = append([]string{"--cache-path", path, "--url-template", cbr.template(), "--currency", "usd", "--date", "14.10.2026"}, tt.args...)
+		if code, _ := runCLI(t, args...); code != exitOK {
+			t.Fatalf("%v: exit code = %d, want %d", tt.args, code, exitOK)
+		}
+
+		for name, want := range map[string]os.FileMode{dir: tt.dir, path: tt.file} {
+			info, err := os.Stat(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode().Perm(); got != want {
+				t.Errorf("%v: %s mode = %o, want %o", tt.args, filepath.Base(name), got, want)
+			}
+		}
+	}
+
+	for _, mode := range []string{"rwx", "0800", "01777"} {
+		if code, _ := runCLI(t, "--cache-dir-mode", mode, "--url-template", cbr.template(), "--date", "14.10.2026"); code != exitUsage {
+			t.Errorf("--cache-dir-mode %s exit code = %d, want %d", mode, code, exitUsage)
+		}
+	}
+}
+
+func TestCachedDayPrecision(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+	var app = cbr.app(t)
+
+	// the day fetched at the first precision renders at the others from the
+	// cache
+	for _, tt := range []struct {
+		precision int
+		want      string
+	}{{2, "92.50"}, {4, "92.5012"}, {0, "93"}, {6, "92.501200"}} {
+		cbr.requests.Store(0)
+		app.opts.rows.precision = tt.precision
+		row, err := reopen(app).getCurrencyItemCache(context.Background(), "usd", testDate, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row[2] != tt.want {
+			t.Errorf("precision %d: rate = %s, want %s", tt.precision, row[2], tt.want)
+		}
+		if n := cbr.requests.Load(); tt.precision != 2 && n != 0 {
+			t.Errorf("precision %d: requests = %d, want 0", tt.precision, n)
+		}
+	}
+
+	// entries of another format version are refetched
+	for _, version := range []int{0, 1, cacheVersion + 1} {
+		err := app.writeCachedDay(testDate, cachedDay{Version: version, Date: testDate, CachedAt: now(), Rates: []cachedRate{{Code: "USD", Nominal: 1, Value: 1}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, ok, err := app.readCachedDay(testDate)
+		if err != nil || ok {
+			t.Errorf("version %d: read = %v, %v, want a miss", version, ok, err)
+		}
+
+		cbr.requests.Store(0)
+		row, err := reopen(app).getCurrencyItemCache(context.Background(), "usd", testDate, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row[2] != "92.501200" || cbr.requests.Load() != 1 {
+			t.Errorf("version %d: rate = %s after %d requests, want the refetched 92.501200", version, row[2], cbr.requests.Load())
+		}
+	}
+}
+
+func TestReadCachedDayConcurrent(t *testing.T) {
+	var app = newTestApp(t)
+	app.opts.provider = testProvider
+	_, err := app.getCurrencyItemCache(context.Background(), "usd", testDate, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a writer holds the write lock while the hits are read
+	tx, err := app.db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	var done = make(chan error)
+	for i := 0; i < 8; i++ {
+		go func() {
+			_, ok, err := app.readCachedDay(testDate)
+			if err == nil && !ok {
+				err = errors.New("cache miss")
+			}
+			done <- err
+		}()
+	}
+
+	var timeout = time.After(5 * time.Second)
+	for i := 0; i < 8; i++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Error(err)
+			}
+		case <-timeout:
+			t.Fatal("cache reads blocked by the write transaction")
+		}
+	}
+}
+
+func TestLoadDayCache(t *testing.T) {
+	var ctx = context.Background()
+
+	// found: the day is fetched and stored
+	var app = newTestApp(t)
+	app.opts.provider = testProvider
+	day, hit, err := app.loadDayCache(ctx, testDate, false)
+	if err != nil || hit || day == nil {
+		t.Fatalf("found: day = %v, hit = %v, error = %v, want a fetched day", day, hit, err)
+	}
+	if cached, ok, err := app.readCachedDay(testDate); !ok || err != nil || len(cached.Rates) != len(testProvider) {
+		t.Errorf("found: cached = %+v, %v, %v, want the stored day", cached, ok, err)
+	}
+
+	// not found: the date is stored as absent and the error returned
+	var cbr = newFakeCBR(t)
+	var absent = cbr.app(t)
+	_, _, err = absent.loadDayCache(ctx, testDate, false)
+	var notPublished *currency.NotPublishedError
+	if !errors.As(err, &notPublished) {
+		t.Errorf("not found: error = %v, want NotPublishedError", err)
+	}
+	if cached, ok, err := absent.readCachedDay(testDate); !ok || err != nil || !cached.Absent {
+		t.Errorf("not found: cached = %+v, %v, %v, want an absent day", cached, ok, err)
+	}
+
+	// write error: the cache error is returned and no transaction is left
+	// open
+	var path = filepath.Join(t.TempDir(), "cache")
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	db, err = bolt.Open(path, 0600, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var readOnly = newApp(db, app.client, newMemoryRates(defaultMemCacheSize), app.opts)
+	_, _, err = readOnly.loadDayCache(ctx, testDate, false)
+	var cacheErr *CacheError
+	if !errors.As(err, &cacheErr) || !errors.Is(err, bolt.ErrDatabaseReadOnly) {
+		t.Errorf("write error: error = %v, want a CacheError of the read-only database", err)
+	}
+	if n := db.Stats().OpenTxN; n != 0 {
+		t.Errorf("write error: open transactions = %d, want 0", n)
+	}
+}
+
+func TestNoCacheWrite(t *testing.T) {
+	var yesterday = testDate.AddDate(0, 0, -1)
+	var cbr = newFakeCBR(t, yesterday, testDate)
+	var app = cbr.app(t)
+
+	var keys = func() (keys []string) {
+		err := app.db.View(func(tx *bolt.Tx) error {
+			var b = tx.Bucket([]byte(cacheBucket))
+			if b == nil {
+				return nil
+			}
+			return b.ForEach(func(k, v []byte) error {
+				keys = append(keys, string(k))
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	_, err := app.getCurrencyItemCache(context.Background(), "usd", yesterday, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = keys()
+
+	// the cached day is read, the missed one fetched but not stored
+	app.opts.cacheWrites = false
+	for _, tt := range []struct {
+		date     time.Time
+		requests int32
+	}{{yesterday, 0}, {testDate, 1}, {testDate, 1}} {
+		cbr.requests.Store(0)
+		row, err := reopen(app).getCurrencyItemCache(context.Background(), "usd", tt.date, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row[0] != ratesKey(tt.date) || cbr.requests.Load() != tt.requests {
+			t.Errorf("%s: row %v after %d requests, want it after %d", ratesKey(tt.date), row, cbr.requests.Load(), tt.requests)
+		}
+	}
+
+	if got := keys(); !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %v, want only %v", got, want)
+	}
+}
+
+func TestCacheStatsAndDump(t *testing.T) {
+	var app = newTestApp(t)
+
+	var rows, err = app.cacheStats()
+	if err != nil || rows != nil {
+		t.Errorf("stats of an empty cache = %v, %v, want no rows", rows, err)
+	}
+	var buf bytes.Buffer
+	if err := app.dumpCache(&buf); err != nil || buf.String() != "[]\n" {
+		t.Errorf("dump of an empty cache = %q, %v, want []", buf.String(), err)
+	}
+
+	// two days and an absent one of the stub provider, a day of the ECB
+	var monday, tuesday = testDate.AddDate(0, 0, -2), testDate.AddDate(0, 0, -1)
+	var rates = []cachedRate{{Code: "USD", Nominal: 1, Value: 92.5012}}
+	var days = []struct {
+		provider Provider
+		t        time.Time
+		day      cachedDay
+	}{
+		{testProvider, testDate, cachedDay{Version: cacheVersion, Date: testDate, Rates: rates}},
+		{testProvider, monday, cachedDay{Version: cacheVersion, Date: monday, Rates: rates}},
+		{testProvider, tuesday, cachedDay{Version: cacheVersion, Date: tuesday, Absent: true}},
+		{ecbProvider{}, tuesday, cachedDay{Version: cacheVersion, Date: tuesday, Rates: rates}},
+	}
+
+	var sizes = map[string]int{}
+	var want = map[string]json.RawMessage{}
+	for _, d := range days {
+		app.opts.provider = d.provider
+		err := app.writeCachedDay(d.t, d.day)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, _ := json.Marshal(d.day)
+		sizes[d.provider.Name()] += len(app.dayCacheKey(d.t)) + len(value)
+		want[app.dayCacheKey(d.t)] = value
+	}
+
+	rows, err = app.cacheStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wantRows = [][]string{
+		{"ecb", "1", "0", "13.10.2026", "13.10.2026", strconv.Itoa(sizes["ecb"])},
+		{"stub", "3", "1", "12.10.2026", "14.10.2026", strconv.Itoa(sizes["stub"])},
+	}
+	if !reflect.DeepEqual(rows, wantRows) {
+		t.Errorf("stats = %v, want %v", rows, wantRows)
+	}
+
+	// every entry is dumped in key order with its stored value
+	buf.Reset()
+	err = app.dumpCache(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []cacheEntry
+	err = json.Unmarshal(buf.Bytes(), &entries)
+	if err != nil {
+		t.Fatalf("decode %s: %v", buf.String(), err)
+	}
+
+	var keys []string
+	for _, e := range entries {
+		keys = append(keys, e.Key)
+		if !bytes.Equal(e.Value, want[e.Key]) {
+			t.Errorf("%s = %s, want %s", e.Key, e.Value, want[e.Key])
+		}
+	}
+	if want := []string{"ecb-13.10.2026", "stub-12.10.2026", "stub-13.10.2026", "stub-14.10.2026"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+}
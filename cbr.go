@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"currency/currency"
+)
+
+// cbrProvider fetches the daily rates of the Central Bank of Russia.
+type cbrProvider struct{}
+
+func (cbrProvider) Name() string {
+	return cbrProviderName
+}
+
+func (cbrProvider) Base() string {
+	return rubCurrency
+}
+
+// validateURLTemplate checks that the --url-template has exactly one %s
+// verb for the date, %% escapes aside, and is an HTTP address.
+func validateURLTemplate(tmpl string) error {
+	var verbs = strings.Count(strings.Replace(tmpl, "%%", "", -1), "%")
+	if verbs != 1 || !strings.Contains(strings.Replace(tmpl, "%%", "", -1), "%s") {
+		return fmt.Errorf("invalid url template '%s', expected exactly one %%s for the date", tmpl)
+	}
+
+	u, err := url.Parse(fmt.Sprintf(tmpl, time.Time{}.Format(xmlDateFormat)))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url template '%s', expected an http or https address", tmpl)
+	}
+	return nil
+}
+
+func (cbrProvider) URL(client *currency.Client, t time.Time) string {
+	return client.DayURL(t)
+}
+
+// History fetches the rates of the currency with the CBR identifier id
+// published from the date to the date.
+func (cbrProvider) History(ctx context.Context, client *currency.Client, id string, from, to time.Time) ([]currency.Record, error) {
+	return client.History(ctx, id, from, to)
+}
+
+// publishedDate parses the publication date of v, t when v has none.
+func publishedDate(v currency.ValCurs, t time.Time) (time.Time, error) {
+	if v.Date == "" {
+		return t, nil
+	}
+	return v.PublishedDate(t.Location())
+}
+
+// cbrValutes converts the published currencies to valutes.
+func cbrValutes(v currency.ValCurs) (valutes []*Valute) {
+	for _, val := range v.Valutes {
+		valutes = append(valutes, &Valute{
+			ID:       val.ID,
+			NumCode:  val.NumCode,
+			CharCode: val.CharCode,
+			Nominal:  val.Nominal,
+			Name:     val.Name,
+			Value:    val.Value,
+		})
+	}
+	return
+}
+
+// Rates fetches the rates for t, walking back to the nearest day with
+// published rates unless the fallback is disabled. The validators are those
+// of the response of the returned day.
+func (cbrProvider) Rates(ctx context.Context, client *currency.Client, t time.Time, fallback bool, cond currency.Validators) (valutes []*Valute, date time.Time, got currency.Validators, err error) {
+	var v currency.ValCurs
+	if fallback {
+		v, got, err = client.Published(ctx, t, cond)
+	} else {
+		v, got, err = client.Daily(ctx, t, cond)
+	}
+	if err != nil {
+		return nil, date, got, err
+	}
+
+	date, err = publishedDate(v, t)
+	if err != nil {
+		return nil, date, got, err
+	}
+
+	if fallback && !date.Equal(t) {
+		logger.with("date", ratesKey(t)).Debugf("no rates published for %s, falling back to %s", t.Format(outputDateFormat), date.Format(outputDateFormat))
+	}
+	return cbrValutes(v), date, got, nil
+}
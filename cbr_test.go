@@ -0,0 +1,212 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"currency/currency"
+)
+
+func TestCBRProviderRates(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	valutes, date, _, err := cbrProvider{}.Rates(context.Background(), cbr.client(), testDate, true, currency.Validators{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !date.Equal(testDate) {
+		t.Errorf("date = %v, want %v", date, testDate)
+	}
+
+	var want = []Valute{
+		{ID: "R01235", NumCode: 840, CharCode: "USD", Nominal: 1, Name: "Доллар США", Value: "92,5012"},
+		{ID: "R01239", NumCode: 978, CharCode: "EUR", Nominal: 1, Name: "Евро", Value: "100,1234"},
+		{ID: "R01820", NumCode: 392, CharCode: "JPY", Nominal: 100, Name: "Японских иен", Value: "62,3456"},
+	}
+	if len(valutes) != len(want) {
+		t.Fatalf("got %d valutes, want %d", len(valutes), len(want))
+	}
+	for i, v := range valutes {
+		if *v != want[i] {
+			t.Errorf("valute %d = %+v, want %+v", i, *v, want[i])
+		}
+	}
+}
+
+func TestCBRProviderFallback(t *testing.T) {
+	var published = testDate.AddDate(0, 0, -2)
+	var cbr = newFakeCBR(t, published)
+
+	_, date, _, err := cbrProvider{}.Rates(context.Background(), cbr.client(), testDate, true, currency.Validators{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !date.Equal(published) {
+		t.Errorf("date = %v, want the fallback to %v", date, published)
+	}
+}
+
+func TestCBRBadBody(t *testing.T) {
+	daily, err := os.ReadFile("testdata/daily.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name string
+		body []byte
+		want string
+	}{
+		{name: "empty", body: nil, want: "empty response from CBR"},
+		{name: "blank", body: []byte("\r\n  \n"), want: "empty response from CBR"},
+		{name: "truncated", body: daily[:len(daily)/2], want: "cannot decode rates for 14.10.2026 from "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/xml; charset=windows-1251")
+				w.Write(tt.body)
+			}))
+			defer srv.Close()
+
+			_, _, _, err := cbrProvider{}.Rates(context.Background(), newTestClient(srv.Client(), srv.URL+"/?date_req=%s"), testDate, true, currency.Validators{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Rates() error = %v, want %q", err, tt.want)
+			}
+			if tt.name == "truncated" && !strings.Contains(err.Error(), srv.URL) {
+				t.Errorf("Rates() error = %v, want the URL", err)
+			}
+		})
+	}
+}
+
+func TestFetchCanceled(t *testing.T) {
+	var started = make(chan struct{})
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	var app = newTestApp(t)
+	app.client = newTestClient(srv.Client(), srv.URL+"/?date_req=%s")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	var start = time.Now()
+	_, err := app.getCurrencyItemCache(ctx, "usd", testDate, true)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("canceled fetch returned after %v", elapsed)
+	}
+}
+
+func TestHTMLBody(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "<!DOCTYPE html><html><body>Service unavailable</body></html>")
+	}))
+	defer srv.Close()
+
+	var app = newTestApp(t)
+	app.client = newTestClient(srv.Client(), srv.URL+"/?date_req=%s")
+
+	_, err := app.getCurrencyItemCache(context.Background(), "usd", testDate, true)
+	if err == nil {
+		t.Fatal("HTML page with status 200 accepted")
+	}
+	for _, want := range []string{"unexpected content type text/html", `body starts with "<!DOCTYPE html><html>`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %v, want it to contain %q", err, want)
+		}
+	}
+}
+
+func TestCompressedBody(t *testing.T) {
+	body, err := os.ReadFile("testdata/daily.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var compressors = map[string]func(io.Writer) io.WriteCloser{
+		"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+	}
+
+	for encoding, compress := range compressors {
+		var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+				http.Error(w, "not acceptable", http.StatusNotAcceptable)
+				return
+			}
+
+			// the windows-1251 document, compressed
+			w.Header().Set("Content-Type", "application/xml; charset=windows-1251")
+			w.Header().Set("Content-Encoding", encoding)
+			var zw = compress(w)
+			zw.Write(body)
+			zw.Close()
+		}))
+		defer srv.Close()
+
+		var client = newTestClient(srv.Client(), srv.URL+"/?date_req=%s")
+		valutes, _, _, err := cbrProvider{}.Rates(context.Background(), client, testDate, false, currency.Validators{})
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		if len(valutes) != 3 || valutes[0].Name != "Доллар США" || valutes[0].Value != "92,5012" {
+			t.Errorf("%s: valutes[0] = %+v, want the decoded USD", encoding, valutes[0])
+		}
+	}
+}
+
+func TestValidateURLTemplate(t *testing.T) {
+	var tests = []struct {
+		tmpl string
+		err  string
+	}{
+		{tmpl: urlTemplate},
+		{tmpl: "http://mirror.test/daily?d=%s"},
+		{tmpl: "https://mirror.test/daily%%20rates?d=%s"},
+		{tmpl: "http://mirror.test/daily", err: "expected exactly one %s"},
+		{tmpl: "http://mirror.test/%s/%s", err: "expected exactly one %s"},
+		{tmpl: "http://mirror.test/%d", err: "expected exactly one %s"},
+		{tmpl: "http://mirror.test/%%s", err: "expected exactly one %s"},
+		{tmpl: "ftp://mirror.test/%s", err: "expected an http or https address"},
+		{tmpl: "/daily?d=%s", err: "expected an http or https address"},
+	}
+
+	for _, tt := range tests {
+		err := validateURLTemplate(tt.tmpl)
+		if tt.err == "" && err != nil {
+			t.Errorf("validateURLTemplate(%q): %v", tt.tmpl, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("validateURLTemplate(%q) error = %v, want %q", tt.tmpl, err, tt.err)
+		}
+	}
+
+	// the default is the CBR address, an invalid override is a usage error
+	if f := newCLI().fs.Lookup("url-template"); f.DefValue != urlTemplate {
+		t.Errorf("default --url-template = %s, want %s", f.DefValue, urlTemplate)
+	}
+	if code, _ := runCLI(t, "--url-template", "http://mirror.test/daily", "--dry-run", "--date", "14.10.2026"); code != exitUsage {
+		t.Errorf("exit code for a template without a verb = %d, want %d", code, exitUsage)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"currency/currency"
+)
+
+const (
+	cbrJSONURLTemplate = "https://www.cbr-xml-daily.ru/archive/%s/daily_json.js"
+	cbrJSONDateFormat  = "2006/01/02"
+)
+
+type cbrJSONDaily struct {
+	Date   string
+	Valute map[string]cbrJSONValute
+}
+
+type cbrJSONValute struct {
+	ID       string
+	NumCode  string
+	CharCode string
+	Nominal  int64
+	Name     string
+	Value    float64
+}
+
+// cbrJSONProvider fetches the CBR daily rates from the UTF-8 JSON mirror
+// at cbr-xml-daily.ru, an alternative when the XML endpoint is flaky. The
+// archive has no document for the days without published rates.
+type cbrJSONProvider struct{}
+
+func (cbrJSONProvider) Name() string {
+	return cbrJSONProviderName
+}
+
+func (cbrJSONProvider) Base() string {
+	return rubCurrency
+}
+
+func (cbrJSONProvider) URL(client *currency.Client, t time.Time) string {
+	return fmt.Sprintf(cbrJSONURLTemplate, t.Format(cbrJSONDateFormat))
+}
+
+func (p cbrJSONProvider) Rates(ctx context.Context, client *currency.Client, t time.Time, fallback bool, cond currency.Validators) (valutes []*Valute, date time.Time, v currency.Validators, err error) {
+	for days := 0; ; days++ {
+		daily, v, err := p.daily(ctx, client, t, cond)
+
+		var se *currency.StatusError
+		if errors.As(err, &se) && se.Code == http.StatusNotFound {
+			if !fallback {
+				return nil, t, v, nil
+			}
+
+			if days >= maxFallbackDays {
+				err = &currency.NotPublishedError{Date: t.AddDate(0, 0, days), Days: maxFallbackDays}
+				return nil, date, v, err
+			}
+
+			logger.with("date", ratesKey(t)).Debugf("no rates published for %s, falling back to the previous day", t.Format(outputDateFormat))
+			t = t.AddDate(0, 0, -1)
+			continue
+		}
+
+		if err != nil {
+			return nil, date, v, err
+		}
+
+		date, err = daily.date(t)
+		if err != nil {
+			return nil, date, v, err
+		}
+
+		valutes, err = daily.valutes()
+		return valutes, date, v, err
+	}
+}
+
+func (p cbrJSONProvider) daily(ctx context.Context, client *currency.Client, t time.Time, cond currency.Validators) (daily cbrJSONDaily, v currency.Validators, err error) {
+	res, v, err := client.Get(ctx, p.URL(client, t), cond)
+	if err != nil {
+		return
+	}
+
+	defer res.Body.Close()
+
+	err = json.NewDecoder(res.Body).Decode(&daily)
+	if err != nil {
+		err = fmt.Errorf("cannot decode rates for %s from %s: %w", t.Format(outputDateFormat), p.URL(client, t), err)
+	}
+	return
+}
+
+// date is the day the rates were published for in the location of t.
+func (d cbrJSONDaily) date(t time.Time) (time.Time, error) {
+	published, err := time.Parse(time.RFC3339, d.Date)
+	if err != nil {
+		return t, fmt.Errorf("invalid CBR JSON date '%s'", d.Date)
+	}
+
+	y, m, day := published.Date()
+	return time.Date(y, m, day, 0, 0, 0, 0, t.Location()), nil
+}
+
+// valutes lists the rates ordered by ID, the order of the XML documents.
+func (d cbrJSONDaily) valutes() (valutes []*Valute, err error) {
+	for _, val := range d.Valute {
+		numCode, err := strconv.ParseInt(val.NumCode, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid numeric code '%s' for %s", val.NumCode, val.CharCode)
+		}
+
+		valutes = append(valutes, &Valute{
+			ID:       val.ID,
+			NumCode:  numCode,
+			CharCode: val.CharCode,
+			Nominal:  val.Nominal,
+			Name:     val.Name,
+			Value:    strconv.FormatFloat(val.Value, 'f', -1, 64),
+		})
+	}
+
+	sort.Slice(valutes, func(i, j int) bool {
+		return valutes[i].ID < valutes[j].ID
+	})
+	return
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCBRJSONRowsMatchXML(t *testing.T) {
+	var codes = []string{"usd", "eur", "jpy"}
+
+	var rows = func(app *App) [][]string {
+		t.Helper()
+		app.opts.rows.withName = true
+		app.opts.rows.withID = true
+		rows, err := app.collectRows(context.Background(), []time.Time{testDate}, codes, false, false, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", app.opts.provider.Name(), err)
+		}
+		return rows
+	}
+
+	var xmlApp = newFakeCBR(t, testDate).app(t)
+	var jsonApp = newTestApp(t)
+	jsonApp.opts.provider = cbrJSONProvider{}
+	jsonApp.client = newTestClient(&http.Client{Transport: fileTransport("testdata/daily.json")}, urlTemplate)
+
+	var want = rows(xmlApp)
+	if got := rows(jsonApp); !reflect.DeepEqual(got, want) {
+		t.Errorf("cbr-json rows = %v, want the XML rows %v", got, want)
+	}
+	if len(want) != len(codes) {
+		t.Errorf("XML rows = %v, want one per currency", want)
+	}
+}
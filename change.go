@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// appendChange appends the absolute and percentage change of the rate for
+// t against the previous business day. The change columns are left blank
+// when there is no rate for the previous day.
+func (a *App) appendChange(ctx context.Context, row []string, name string, t time.Time, skipCache bool) []string {
+	// never append into the backing array of an in-memory row
+	row = row[:len(row):len(row)]
+
+	cur, err := a.getCurrencyValueCache(ctx, name, t, skipCache)
+	if err != nil {
+		return append(row, "", "")
+	}
+
+	day, err := a.dayRatesCache(ctx, t, skipCache)
+	if err != nil {
+		return append(row, "", "")
+	}
+
+	var prevDate = day.date.AddDate(0, 0, -1)
+	prev, err := a.getCurrencyValueCache(ctx, name, prevDate, skipCache)
+	if err != nil {
+		return append(row, "", "")
+	}
+
+	return append(row, changeColumns(cur, prev, a.opts.rows)...)
+}
+
+func changeColumns(cur, prev float64, o rowOptions) []string {
+	var change = cur - prev
+	var percent string
+	if prev != 0 {
+		percent = o.format(change / prev * 100)
+	}
+
+	return []string{
+		o.format(change),
+		percent,
+	}
+}
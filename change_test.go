@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"currency/currency"
+)
+
+// usdProvider publishes USD at the value of each date, no rates on the
+// other dates.
+type usdProvider struct {
+	stubProvider
+	values map[string]string
+}
+
+func (p usdProvider) Rates(ctx context.Context, client *currency.Client, t time.Time, fallback bool, cond currency.Validators) ([]*Valute, time.Time, currency.Validators, error) {
+	value, ok := p.values[ratesKey(t)]
+	if !ok {
+		return nil, t, currency.Validators{}, &currency.NotPublishedError{Date: t, Days: maxFallbackDays}
+	}
+	return []*Valute{{CharCode: "USD", Nominal: 1, Value: value}}, t, currency.Validators{}, nil
+}
+
+func TestChangeColumns(t *testing.T) {
+	var got = changeColumns(92.5, 90, rowOptions{precision: defaultPrecision})
+	if want := []string{"2.50", "2.78"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("change of 90 to 92.5 = %v, want %v", got, want)
+	}
+
+	got = changeColumns(90, 0, rowOptions{precision: defaultPrecision})
+	if want := []string{"90.00", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("change from 0 = %v, want %v", got, want)
+	}
+}
+
+func TestAppendChange(t *testing.T) {
+	var app = newTestApp(t)
+	app.opts.provider = usdProvider{values: map[string]string{
+		"13.10.2026": "90",
+		"14.10.2026": "92,5",
+	}}
+
+	var tests = []struct {
+		t    time.Time
+		want []string
+	}{
+		{t: testDate, want: []string{"2.50", "2.78"}},
+		// no rates the day before, the change is blank
+		{t: testDate.AddDate(0, 0, -1), want: []string{"", ""}},
+	}
+
+	for _, tt := range tests {
+		row, err := app.getCurrencyItemCache(context.Background(), "usd", tt.t, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		row = app.appendChange(context.Background(), row, "usd", tt.t, false)
+		if got := row[len(row)-2:]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("change on %s = %v, want %v", ratesKey(tt.t), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,767 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"currency/currency"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/time/rate"
+)
+
+// cliFlags are the values of the command line flags.
+type cliFlags struct {
+	currency, currencyMap, codesFile              string
+	batch, dryRun, all                            bool
+	skipCache, noWrite, offline, freshness        bool
+	explain, verbose, quiet                       bool
+	logFormat                                     string
+	daysBefore                                    int
+	since, date, fromDate, toDate, compare        string
+	future                                        bool
+	format, sortBy, fields, lang, dateLayout      string
+	pretty, appendOut, pivot, summary             bool
+	sqlitePath                                    string
+	from, to                                      string
+	amount                                        float64
+	failFast, noFallback, strict, validate        bool
+	validateMax, minRate, maxRate                 float64
+	rateLimit                                     float64
+	retries, precision, sigFigs, limit, offset    int
+	roundMode, colorMode, codeCase                string
+	raw, trimZeros, showChange                    bool
+	withName, withID, inverse, requestedDate      bool
+	cacheTTL, watch, timeout, budget, idleTimeout time.Duration
+	provider, urlTemplate, xmlFile, base          string
+	cachePath, dirMode, fileMode                  string
+	memSize, concurrency, idleConns, idlePerHost  int
+	listen, userAgent, proxy                      string
+	caFile, clientCert, clientKey                 string
+	version                                       bool
+	config                                        string
+	outputs                                       outputPaths
+}
+
+// register defines the flags on fs.
+func (f *cliFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.currency, "currency", usdCurrency, "currency code, alphabetic or numeric (840)")
+	fs.BoolVar(&f.batch, "stdin", false, "read '<code> <date>' lines from stdin and emit a row for each")
+	fs.BoolVar(&f.dryRun, "dry-run", false, "print the provider URLs instead of fetching the rates")
+	fs.BoolVar(&f.all, "all", false, "print every currency published for the date")
+	fs.StringVar(&f.currencyMap, "currency-map", "", "file of alias=code lines naming currencies by custom symbols")
+	fs.StringVar(&f.codesFile, "currencies-file", "", "file with a currency code per line, merged with --currency")
+	fs.BoolVar(&f.skipCache, "skip-cache", false, "skip cache")
+	fs.BoolVar(&f.noWrite, "no-cache-write", false, "read the cache but never store fetched rates in it")
+	fs.BoolVar(&f.offline, "offline", false, "use cached rates only, never query the provider")
+	fs.BoolVar(&f.explain, "explain", false, "trace to stderr how the rate of each currency was resolved")
+	fs.StringVar(&f.logFormat, "log-format", textLogFormat, "log format: text or json lines with level, message and fields")
+	fs.BoolVar(&f.verbose, "verbose", false, "log requests, cache lookups and fallback decisions")
+	fs.BoolVar(&f.quiet, "quiet", false, "log fatal errors only")
+	fs.IntVar(&f.daysBefore, "days-before", 0, "get currency rate in date x days before")
+	fs.StringVar(&f.since, "since", "", "get currency rate this long before today: 7d, 2w, 1mo or 1y")
+	fs.StringVar(&f.date, "date", "", "get currency rate in this date ("+outputDateFormat+"), overrides --days-before")
+	fs.StringVar(&f.fromDate, "from-date", "", "first date of a date range ("+outputDateFormat+")")
+	fs.StringVar(&f.toDate, "to-date", "", "last date of a date range ("+outputDateFormat+")")
+	fs.BoolVar(&f.future, "allow-future", false, "allow --date in the future")
+	fs.StringVar(&f.format, "format", tsvFormat, "output format: tsv, csv, json, ndjson, markdown or xml")
+	fs.BoolVar(&f.pretty, "json-pretty", false, "indent the json output")
+	fs.BoolVar(&f.appendOut, "append", false, "append to the --output files instead of truncating them")
+	fs.StringVar(&f.sqlitePath, "export-sqlite", "", "also upsert the rates into the rates table of this SQLite database")
+	fs.StringVar(&f.from, "from", "", "convert amount from this currency, or from each of comma-separated currencies")
+	fs.StringVar(&f.to, "to", "", "convert amount to this currency")
+	fs.Float64Var(&f.amount, "amount", 1, "amount to convert")
+	fs.BoolVar(&f.summary, "summary", false, "append the total of the conversions, or the min, max and average rate")
+	fs.BoolVar(&f.failFast, "fail-fast", true, "stop at the first currency that fails, otherwise print the other rows and report the failures at the end")
+	fs.BoolVar(&f.noFallback, "no-fallback", false, "do not fall back to the previous business day when no rates are published")
+	fs.Float64Var(&f.rateLimit, "rate-limit", 0, "provider requests per second shared by all workers, 0 for no limit")
+	fs.IntVar(&f.retries, "retries", defaultRetries, "retry count for failed requests")
+	fs.BoolVar(&f.raw, "raw", false, "emit the published value per nominal instead of the rate per unit")
+	fs.StringVar(&f.dateLayout, "date-format", outputDateFormat, "layout of the written dates: iso, eu, us or a Go layout")
+	fs.IntVar(&f.precision, "precision", defaultPrecision, "decimal places of the rate")
+	fs.IntVar(&f.sigFigs, "sig-figs", 0, "significant digits of the rate instead of --precision decimal places")
+	fs.StringVar(&f.roundMode, "round-mode", roundHalfEven, "rounding of the rate to --precision: half-even or half-up")
+	fs.BoolVar(&f.trimZeros, "trim-zeros", false, "drop trailing zeros after rounding to --precision")
+	fs.BoolVar(&f.showChange, "show-change", false, "append change against the previous business day")
+	fs.StringVar(&f.colorMode, "color", colorAuto, "colorize the tsv change columns: auto, always or never")
+	fs.StringVar(&f.codeCase, "code-case", codeCaseUpper, "case of the emitted currency codes: upper, lower or as-is")
+	fs.StringVar(&f.fields, "fields", "", "comma-separated columns to write in order, enabling the optional ones: date,code,rate,name")
+	fs.BoolVar(&f.withName, "with-name", false, "append currency name column")
+	fs.StringVar(&f.lang, "lang", "ru", "language of the currency names: ru or en, other languages fall back to the published Russian names")
+	fs.BoolVar(&f.inverse, "inverse", false, "append the units of the currency per unit of the base")
+	fs.BoolVar(&f.withID, "with-id", false, "append the CBR currency ID column (R01235)")
+	fs.BoolVar(&f.requestedDate, "show-requested-date", false, "append the requested date column next to the publication date")
+	fs.BoolVar(&f.strict, "strict", false, "fail when the provider publishes a currency code more than once or --validate finds a rate implausible")
+	fs.BoolVar(&f.validate, "validate", false, "warn about rates per unit that are not positive or above --validate-max")
+	fs.Float64Var(&f.validateMax, "validate-max", defaultMaxRate, "largest plausible rate per unit checked by --validate")
+	fs.BoolVar(&f.freshness, "check-freshness", false, "warn when today's cached rates differ from the provider's")
+	fs.DurationVar(&f.cacheTTL, "cache-ttl", defaultCacheTTL, "how long today's cached rates stay fresh")
+	fs.Float64Var(&f.minRate, "min-rate", 0, "only emit the currencies with at least this rate")
+	fs.Float64Var(&f.maxRate, "max-rate", 0, "only emit the currencies with at most this rate")
+	fs.StringVar(&f.compare, "compare", "", "compare the rates of two comma-separated dates ("+outputDateFormat+","+outputDateFormat+")")
+	fs.BoolVar(&f.pivot, "pivot", false, "print a date range as a table of currencies by dates")
+	fs.IntVar(&f.limit, "limit", 0, "emit at most this many rows after sorting, 0 for all")
+	fs.IntVar(&f.offset, "offset", 0, "skip this many rows after sorting")
+	fs.StringVar(&f.sortBy, "sort", sortByInput, "sort order: input, code or rate; input, code or name for list (default code)")
+	fs.StringVar(&f.provider, "provider", cbrProviderName, "rates provider: cbr, cbr-json or ecb")
+	fs.StringVar(&f.urlTemplate, "url-template", urlTemplate, "address of the CBR daily rates with one %s for the date ("+xmlDateFormat+"), for mirrors")
+	fs.StringVar(&f.xmlFile, "xml-file", "", "read the rates from this saved CBR XML file instead of the provider")
+	fs.StringVar(&f.base, "base", "", "quote rates against this currency (default the provider base)")
+	fs.StringVar(&f.cachePath, "cache-path", "", "cache file path (env "+cachePathEnv+")")
+	fs.StringVar(&f.dirMode, "cache-dir-mode", defaultCacheDirMode, "octal permissions of a created cache directory")
+	fs.StringVar(&f.fileMode, "cache-file-mode", defaultCacheFileMode, "octal permissions of a created cache file")
+	fs.IntVar(&f.memSize, "mem-cache-size", defaultMemCacheSize, "number of days kept in memory in front of the cache file")
+	fs.IntVar(&f.concurrency, "concurrency", defaultConcurrency, "number of dates fetched in parallel")
+	fs.DurationVar(&f.watch, "watch", 0, "reprint the rates at this interval until interrupted")
+	fs.StringVar(&f.listen, "listen", defaultListen, "listen address of the serve command")
+	fs.DurationVar(&f.timeout, "timeout", defaultTimeout, "HTTP request timeout (env "+timeoutEnv+")")
+	fs.DurationVar(&f.budget, "timeout-total", 0, "time limit of fetching all the rates, the rows gathered by then are printed")
+	fs.StringVar(&f.userAgent, "user-agent", defaultUserAgent, "User-Agent header of provider requests (env "+userAgentEnv+")")
+	fs.IntVar(&f.idleConns, "max-idle-conns", defaultConnPool.maxIdle, "idle provider connections kept for reuse, 0 for no limit")
+	fs.IntVar(&f.idlePerHost, "max-idle-conns-per-host", defaultConnPool.maxIdlePerHost, "idle connections kept for reuse per provider host")
+	fs.DurationVar(&f.idleTimeout, "idle-conn-timeout", defaultConnPool.idleTimeout, "how long an idle provider connection is kept, 0 for no limit")
+	fs.StringVar(&f.proxy, "proxy", "", "HTTP or SOCKS5 proxy URL, overrides HTTP_PROXY and HTTPS_PROXY")
+	fs.StringVar(&f.caFile, "ca-file", "", "PEM file of CA certificates trusted along with the system ones")
+	fs.StringVar(&f.clientCert, "client-cert", "", "PEM client certificate presented to the provider and the proxy")
+	fs.StringVar(&f.clientKey, "client-key", "", "PEM private key of --client-cert")
+	fs.BoolVar(&f.version, "version", false, "print the version and exit")
+	var configUsage = "config file path"
+	if path := defaultConfigPath(); path != "" {
+		configUsage += " (default " + path + ")"
+	}
+	fs.StringVar(&f.config, "config", "", configUsage)
+	fs.Var(&f.outputs, "output", "write results to this file instead of stdout, repeat for several destinations, - for stdout")
+}
+
+// cli is a parsed command line.
+type cli struct {
+	cliFlags
+	fs *flag.FlagSet
+	// command and subcommand are the leading non-flag arguments
+	command, subcommand string
+	// cmdline are the flags set on the command line, not by the config
+	cmdline map[string]bool
+}
+
+func newCLI() *cli {
+	var c = &cli{fs: flag.NewFlagSet("currency", flag.ContinueOnError)}
+	c.register(c.fs)
+	return c
+}
+
+// request is what the command line asks for.
+type request struct {
+	date        time.Time
+	dates       []time.Time
+	isRange     bool
+	compareFrom time.Time
+	compareTo   time.Time
+	currencies  []string
+	band        rateBand
+}
+
+// parse parses the arguments. The first non-flag argument selects a
+// command, flags may follow it.
+func (c *cli) parse(args []string) error {
+	err := c.fs.Parse(args)
+	if err != nil || c.fs.NArg() == 0 {
+		return err
+	}
+
+	c.command = c.fs.Arg(0)
+	args = c.fs.Args()[1:]
+	if c.command == cacheCommand && len(args) > 0 {
+		c.subcommand, args = args[0], args[1:]
+	}
+
+	err = c.fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if c.fs.NArg() > 0 {
+		return usageErrorf("unexpected arguments: %v", c.fs.Args())
+	}
+	return nil
+}
+
+// setupLogger applies --log-format, --verbose and --quiet to the logger.
+func (c *cli) setupLogger() error {
+	switch c.logFormat {
+	case textLogFormat:
+		logger = newLogger(os.Stderr, logger.level)
+	case jsonLogFormat:
+		logger = newJSONLogger(os.Stderr, logger.level)
+	default:
+		return usageErrorf("unknown log format '%s', expected %s or %s", c.logFormat, textLogFormat, jsonLogFormat)
+	}
+
+	switch {
+	case c.verbose && c.quiet:
+		return usageErrorf("--verbose and --quiet are mutually exclusive")
+	case c.verbose:
+		logger.level = levelDebug
+	case c.quiet:
+		logger.level = levelQuiet
+	}
+	return nil
+}
+
+// applyConfig sets the flags the config file has values for. The checks
+// of what was asked for look at the command line only.
+func (c *cli) applyConfig() error {
+	var path = c.config
+	if path == "" {
+		path = defaultConfigPath()
+	}
+
+	c.cmdline = setFlags(c.fs)
+	if path == "" {
+		return nil
+	}
+
+	cfg, err := loadConfig(path, c.config != "")
+	if err != nil {
+		return &UsageError{Err: err}
+	}
+
+	err = cfg.apply(c.fs)
+	if err != nil {
+		return &UsageError{Err: err}
+	}
+	return nil
+}
+
+// request resolves the dates asked for and checks the flags that select
+// the output.
+func (c *cli) request() (req request, err error) {
+	err = validateFormat(c.format)
+	if err != nil {
+		return req, &UsageError{Err: err}
+	}
+
+	if c.pretty && c.format != jsonFormat {
+		return req, usageErrorf("--json-pretty requires --format %s", jsonFormat)
+	}
+
+	err = validateColor(c.colorMode)
+	if err != nil {
+		return req, &UsageError{Err: err}
+	}
+
+	if c.command != listCommand {
+		err = validateSort(c.sortBy)
+		if err != nil {
+			return req, &UsageError{Err: err}
+		}
+	}
+
+	req.date, err = resolveDate(c.date, c.daysBefore, c.future)
+	if err != nil {
+		return req, &UsageError{Err: err}
+	}
+
+	if c.since != "" {
+		if c.date != "" || isFlagSet(c.fs, "days-before") {
+			return req, usageErrorf("--since cannot be combined with --date or --days-before")
+		}
+
+		req.date, err = resolveSince(c.since)
+		if err != nil {
+			return req, &UsageError{Err: err}
+		}
+	}
+
+	req.dates = []time.Time{req.date}
+	req.isRange = c.fromDate != "" || c.toDate != ""
+	if req.isRange {
+		req.dates, err = resolveDateRange(c.fromDate, c.toDate, c.future)
+		if err != nil {
+			return req, &UsageError{Err: err}
+		}
+	}
+
+	if c.pivot && (!req.isRange || c.command != "" || c.batch) {
+		return req, usageErrorf("--pivot needs a date range of rates, --from-date or --to-date")
+	}
+
+	if c.limit < 0 || c.offset < 0 {
+		return req, usageErrorf("--limit and --offset must not be negative")
+	}
+
+	if c.summary && (c.pivot || c.compare != "" || c.command != "" || c.format == xmlFormat) {
+		return req, usageErrorf("--summary applies to rates and conversions only")
+	}
+
+	if c.compare != "" {
+		if req.isRange || c.pivot || c.batch || c.command != "" || c.date != "" || c.since != "" || isFlagSet(c.fs, "days-before") {
+			return req, usageErrorf("--compare takes its own dates and applies to rates only")
+		}
+
+		req.compareFrom, req.compareTo, err = parseCompare(c.compare, c.future)
+		if err != nil {
+			return req, &UsageError{Err: err}
+		}
+	}
+
+	if c.offline && c.skipCache {
+		return req, usageErrorf("--offline and --skip-cache are mutually exclusive")
+	}
+
+	if c.freshness && (c.offline || req.isRange || !truncateDay(req.date).Equal(truncateDay(now()))) {
+		return req, usageErrorf("--check-freshness applies to today's rates only and needs the network")
+	}
+
+	req.band, err = newRateBand(c.minRate, c.maxRate, isFlagSet(c.fs, "min-rate"), isFlagSet(c.fs, "max-rate"))
+	if err != nil {
+		return req, &UsageError{Err: err}
+	}
+	return req, nil
+}
+
+// options builds the App options of the flags.
+func (c *cli) options() (opts options, err error) {
+	opts = defaultOptions()
+	opts.fallback = !c.noFallback
+	opts.failFast = c.failFast
+	opts.strict = c.strict
+	opts.allCodes = c.all
+	opts.offline = c.offline
+	opts.cacheWrites = !c.noWrite
+	opts.cacheTTL = c.cacheTTL
+	opts.prettyJSON = c.pretty
+	if c.explain {
+		opts.explain = os.Stderr
+	}
+
+	err = validatePrecision(c.precision)
+	if err != nil {
+		return opts, &UsageError{Err: err}
+	}
+	if isFlagSet(c.fs, "sig-figs") {
+		if c.cmdline["precision"] {
+			return opts, usageErrorf("--sig-figs and --precision are mutually exclusive")
+		}
+
+		err = validateSigFigs(c.sigFigs)
+		if err != nil {
+			return opts, &UsageError{Err: err}
+		}
+	}
+	err = validateRoundMode(c.roundMode)
+	if err != nil {
+		return opts, &UsageError{Err: err}
+	}
+	err = validateCodeCase(c.codeCase)
+	if err != nil {
+		return opts, &UsageError{Err: err}
+	}
+
+	layout, err := resolveDateFormat(c.dateLayout)
+	if err != nil {
+		return opts, &UsageError{Err: err}
+	}
+
+	if c.validateMax <= 0 {
+		return opts, usageErrorf("--validate-max must be positive")
+	}
+
+	lang, err := resolveLanguage(c.lang)
+	if err != nil {
+		return opts, &UsageError{Err: err}
+	}
+
+	opts.rows = rowOptions{
+		withName:          c.withName,
+		withID:            c.withID,
+		withInverse:       c.inverse,
+		raw:               c.raw,
+		precision:         c.precision,
+		sigFigs:           c.sigFigs,
+		roundMode:         c.roundMode,
+		trimZeros:         c.trimZeros,
+		withRequestedDate: c.requestedDate,
+		withChange:        c.showChange,
+		dateFormat:        layout,
+		validate:          c.validate,
+		maxRate:           c.validateMax,
+		codeCase:          c.codeCase,
+		lang:              lang,
+	}
+
+	if c.fields != "" {
+		if c.pivot || c.compare != "" {
+			return opts, usageErrorf("--fields applies to rate rows only")
+		}
+
+		opts.rows.fields, err = parseFields(c.fields, &opts.rows)
+		if err != nil {
+			return opts, &UsageError{Err: err}
+		}
+
+		// the summary rows are named in the code column
+		if c.summary && (columnIndex(opts.rows.fields, "code") < 0 || columnIndex(opts.rows.fields, "rate") < 0) {
+			return opts, usageErrorf("--summary requires the code and rate --fields")
+		}
+	}
+
+	opts.provider, err = getProvider(c.provider)
+	if err != nil {
+		return opts, &UsageError{Err: err}
+	}
+
+	// the file is read on every run, never from or into the cache
+	if c.xmlFile != "" {
+		if isFlagSet(c.fs, "provider") || c.offline || c.freshness {
+			return opts, usageErrorf("--xml-file cannot be combined with --provider, --offline or --check-freshness")
+		}
+		opts.provider = xmlFileProvider{path: c.xmlFile}
+		opts.cacheWrites = false
+		c.skipCache = true
+	}
+
+	if c.currencyMap != "" {
+		opts.aliases, err = readCurrencyMap(c.currencyMap)
+		if err != nil {
+			return opts, &UsageError{Err: err}
+		}
+	}
+
+	opts.base = strings.ToLower(strings.TrimSpace(opts.resolveAlias(c.base)))
+	if opts.base != "" {
+		err = validateCode(opts.base)
+		if err != nil {
+			return opts, &UsageError{Err: err}
+		}
+		if isNumericCode(opts.base) {
+			return opts, usageErrorf("--base expects an alphabetic currency code")
+		}
+	}
+	return opts, nil
+}
+
+// client builds the client of the provider requests.
+func (c *cli) client(provider string) (*currency.Client, error) {
+	timeout, err := resolveTimeout(c.timeout, isFlagSet(c.fs, "timeout"), os.Getenv(timeoutEnv))
+	if err != nil {
+		return nil, &UsageError{Err: err}
+	}
+
+	proxy, err := parseProxy(c.proxy)
+	if err != nil {
+		return nil, &UsageError{Err: err}
+	}
+
+	if c.idleConns < 0 || c.idlePerHost < 0 || c.idleTimeout < 0 {
+		return nil, usageErrorf("--max-idle-conns, --max-idle-conns-per-host and --idle-conn-timeout must not be negative")
+	}
+
+	tlsConfig, err := loadTLSConfig(c.caFile, c.clientCert, c.clientKey)
+	if err != nil {
+		return nil, &UsageError{Err: err}
+	}
+
+	var httpClient = newHTTPClient(timeout, proxy, connPool{
+		maxIdle:        c.idleConns,
+		maxIdlePerHost: c.idlePerHost,
+		idleTimeout:    c.idleTimeout,
+	}, tlsConfig)
+
+	var agent = c.userAgent
+	if env := os.Getenv(userAgentEnv); env != "" && !isFlagSet(c.fs, "user-agent") {
+		agent = env
+	}
+
+	if c.rateLimit < 0 {
+		return nil, usageErrorf("--rate-limit must not be negative")
+	}
+	var limiter = rate.NewLimiter(rate.Inf, 1)
+	if c.rateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(c.rateLimit), 1)
+	}
+
+	if c.retries < 0 {
+		return nil, usageErrorf("--retries must not be negative")
+	}
+
+	err = validateURLTemplate(c.urlTemplate)
+	if err != nil {
+		return nil, &UsageError{Err: err}
+	}
+	return newClient(httpClient, c.urlTemplate, agent, c.retries, limiter, provider), nil
+}
+
+// checkLimits checks the flags that bound the work of a run.
+func (c *cli) checkLimits() error {
+	switch {
+	case c.watch < 0:
+		return usageErrorf("--watch must not be negative")
+	case c.budget < 0:
+		return usageErrorf("--timeout-total must not be negative")
+	case c.memSize < 1:
+		return usageErrorf("--mem-cache-size must be at least 1")
+	case c.concurrency < 1:
+		return usageErrorf("--concurrency must be at least 1")
+	}
+	return nil
+}
+
+// currencies returns the requested currency codes, none for the commands
+// and --all.
+func (c *cli) currencies(opts options) ([]string, error) {
+	if c.all && (c.cmdline["currency"] || c.codesFile != "") {
+		return nil, usageErrorf("--all cannot be combined with --currency or --currencies-file")
+	}
+	if c.command != "" || c.all {
+		return nil, nil
+	}
+
+	codes, err := opts.requestedCurrencies(c.currency, c.cmdline["currency"], c.codesFile)
+	if err != nil {
+		return nil, &UsageError{Err: err}
+	}
+	return codes, nil
+}
+
+// printURLs writes the provider URLs of the dates for --dry-run.
+func (c *cli) printURLs(w io.Writer, provider Provider, client *currency.Client, dates []time.Time) error {
+	if c.command != "" {
+		return usageErrorf("--dry-run does not apply to the %s command", c.command)
+	}
+
+	for _, d := range dates {
+		_, err := fmt.Fprintln(w, provider.URL(client, d))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openCache opens the cache file, creating it and its directory.
+func (c *cli) openCache() (db *bolt.DB, path string, err error) {
+	path = resolveCachePath(c.cachePath, os.Getenv(cachePathEnv))
+	logger.with("path", path).Debugf("using cache file %s", path)
+
+	dirMode, err := parseMode(c.dirMode)
+	if err != nil {
+		return nil, path, &UsageError{Err: err}
+	}
+
+	fileMode, err := parseMode(c.fileMode)
+	if err != nil {
+		return nil, path, &UsageError{Err: err}
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), dirMode)
+	if err != nil {
+		return nil, path, &CacheError{Err: err}
+	}
+
+	db, err = bolt.Open(path, fileMode, nil)
+	if err != nil {
+		return nil, path, &CacheError{Err: err}
+	}
+	return db, path, nil
+}
+
+// execute runs the command, or prints the rates without one.
+func (c *cli) execute(ctx context.Context, app *App, w io.Writer, req request, cachePath string) error {
+	switch c.command {
+	case "":
+	case listCommand:
+		var sortBy = c.sortBy
+		if !isFlagSet(c.fs, "sort") {
+			sortBy = sortByCode
+		}
+
+		rows, err := app.listCurrencies(ctx, req.date, sortBy, req.band, c.skipCache)
+		if err != nil {
+			return err
+		}
+		return app.writeTable(w, c.format, listHeader, pageRows(rows, c.offset, c.limit))
+	case cacheCommand:
+		return c.cacheCommand(app, w, cachePath)
+	case serveCommand:
+		return app.serve(ctx, c.listen)
+	default:
+		return usageErrorf("unknown command '%s'", c.command)
+	}
+
+	switch {
+	case c.from != "" || c.to != "":
+		return c.convert(ctx, app, w, req)
+	case c.compare != "":
+		rows, err := app.compareRows(ctx, req.compareFrom, req.compareTo, req.currencies, c.skipCache)
+		if err != nil {
+			return err
+		}
+		return app.writeTable(w, c.format, compareHeader, rows)
+	case c.batch:
+		return c.readBatch(ctx, app, w, req)
+	case c.watch > 0:
+		// ndjson rows accumulate, the screen is not cleared
+		return app.watch(ctx, w, c.watch, c.format == ndjsonFormat, func() error {
+			return c.printRates(ctx, app, w, req)
+		})
+	}
+	return c.printRates(ctx, app, w, req)
+}
+
+// cacheCommand runs the cache subcommand.
+func (c *cli) cacheCommand(app *App, w io.Writer, cachePath string) error {
+	switch c.subcommand {
+	case clearCommand:
+		if !app.opts.cacheWrites {
+			return usageErrorf("--no-cache-write cannot be combined with the cache %s command", clearCommand)
+		}
+
+		n, err := app.clearCache()
+		if err != nil {
+			return &CacheError{Err: err}
+		}
+
+		_, err = fmt.Fprintf(w, "removed %d cached entries from %s\n", n, cachePath)
+		return err
+	case statsCommand:
+		rows, err := app.cacheStats()
+		if err != nil {
+			return &CacheError{Err: err}
+		}
+		return app.writeTable(w, c.format, cacheStatsHeader, rows)
+	case dumpCommand:
+		err := app.dumpCache(w)
+		if err != nil {
+			return &CacheError{Err: err}
+		}
+		return nil
+	}
+	return usageErrorf("unknown cache command '%s', expected %s, %s or %s", c.subcommand, clearCommand, statsCommand, dumpCommand)
+}
+
+// convert converts --amount from each of the --from currencies to --to.
+func (c *cli) convert(ctx context.Context, app *App, w io.Writer, req request) error {
+	if c.from == "" || c.to == "" {
+		return usageErrorf("both --from and --to are required for conversion")
+	}
+
+	var fromCodes = strings.Split(c.from, ",")
+	for i := range fromCodes {
+		fromCodes[i] = app.opts.resolveAlias(fromCodes[i])
+	}
+	fromCodes = dedupCodes(fromCodes)
+
+	var toCode = app.opts.resolveAlias(c.to)
+	for _, code := range append([]string{toCode}, fromCodes...) {
+		err := validateCode(code)
+		if err != nil {
+			return &UsageError{Err: err}
+		}
+	}
+
+	// a single conversion prints the bare value
+	if len(fromCodes) == 1 && !c.summary {
+		result, err := app.convert(ctx, fromCodes[0], toCode, c.amount, req.date, c.skipCache)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(w, app.opts.rows.format(result))
+		return err
+	}
+
+	rows, err := app.conversionRows(ctx, fromCodes, toCode, c.amount, req.date, c.skipCache, c.summary)
+	if err != nil {
+		return err
+	}
+	return app.writeTable(w, c.format, conversionHeader, rows)
+}
+
+// readBatch prints a row for each '<code> <date>' line of stdin and
+// reports the failed lines at the end.
+func (c *cli) readBatch(ctx context.Context, app *App, w io.Writer, req request) error {
+	rows, errs := app.readBatch(ctx, os.Stdin, c.skipCache)
+	rows = req.band.filter(rows, app.published.value)
+	err := app.writeSummarized(w, c.format, rows, c.summary)
+	if err != nil {
+		return err
+	}
+
+	if c.sqlitePath != "" {
+		err = app.exportSQLite(ctx, c.sqlitePath, rows)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, err := range errs {
+		logger.Errorf("%v", err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d line(s) failed, %d row(s) written", len(errs), len(rows))
+	}
+	return nil
+}
+
+// printRates prints the rates of the requested dates and currencies.
+func (c *cli) printRates(ctx context.Context, app *App, w io.Writer, req request) error {
+	// --timeout-total bounds the fetching only, not the output
+	var fetchCtx = ctx
+	if c.budget > 0 {
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithTimeout(ctx, c.budget)
+		defer cancel()
+	}
+
+	if req.isRange && len(req.currencies) == 1 {
+		err := app.loadHistory(fetchCtx, req.currencies[0], req.dates, c.skipCache)
+		if err != nil {
+			logger.Debugf("no rate history, fetching every day: %v", err)
+		}
+	}
+
+	// without --fail-fast the rows are written before the errors are reported
+	rows, collectErr := app.collectRows(fetchCtx, req.dates, req.currencies, c.skipCache, req.isRange, c.concurrency)
+	if collectErr != nil && app.opts.failFast && !budgetExceeded(fetchCtx) {
+		return collectErr
+	}
+
+	rows = req.band.filter(rows, app.published.value)
+
+	err := sortRows(rows, c.sortBy)
+	if err != nil {
+		return err
+	}
+
+	// the page applies to the written rows, not to the export
+	if c.pivot {
+		header, pivoted := pivotRows(req.dates, rows)
+		err = app.writePivot(w, c.format, header, pageRows(pivoted, c.offset, c.limit))
+	} else {
+		err = app.writeSummarized(w, c.format, pageRows(rows, c.offset, c.limit), c.summary)
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.sqlitePath != "" {
+		err = app.exportSQLite(ctx, c.sqlitePath, rows)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.freshness {
+		err = app.checkFreshness(ctx, req.date, req.currencies)
+		if err != nil {
+			logger.Warnf("cannot check freshness: %v", err)
+		}
+	}
+	return collectErr
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"currency/currency"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultTimeout = currency.DefaultTimeout
+	timeoutEnv     = "CURRENCY_HTTP_TIMEOUT"
+	userAgentEnv   = "CURRENCY_USER_AGENT"
+	maxRedirects   = 3
+	defaultRetries = currency.DefaultRetries
+)
+
+// connPool tunes the idle provider connections kept for reuse.
+type connPool struct {
+	maxIdle        int
+	maxIdlePerHost int
+	idleTimeout    time.Duration
+}
+
+// defaultConnPool keeps enough connections per host for the concurrent
+// fetches of a date range, the default transport keeps only two.
+var defaultConnPool = connPool{
+	maxIdle:        100,
+	maxIdlePerHost: 16,
+	idleTimeout:    90 * time.Second,
+}
+
+// checkRedirect follows at most maxRedirects redirects.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > maxRedirects {
+		return fmt.Errorf("%w, stopped after %d", currency.ErrTooManyRedirects, maxRedirects)
+	}
+
+	logger.with("url", req.URL.String()).Debugf("redirected to %s", req.URL)
+	return nil
+}
+
+// newClient builds the client of the rates documents: requests are made
+// with httpClient, wait for the limiter and are logged and counted as
+// requests of the provider.
+func newClient(httpClient *http.Client, urlTemplate, agent string, retries int, limiter *rate.Limiter, provider string) *currency.Client {
+	return &currency.Client{
+		HTTPClient:  httpClient,
+		UserAgent:   agent,
+		URLTemplate: urlTemplate,
+		Retries:     retries,
+		Wait:        limiter.Wait,
+		OnRequest: func(url string) {
+			logger.with("url", url).Debugf("GET %s", url)
+			providerRequests.WithLabelValues(provider).Inc()
+		},
+		OnRetry: func(url string, attempt int, err error, delay time.Duration) {
+			logger.with("url", url, "attempt", attempt).Infof("attempt %d of %s failed: %v, retrying in %s", attempt, url, err, delay)
+		},
+	}
+}
+
+// newHTTPClient builds the client of the providers. Requests go through
+// the proxy when it is set, through the proxy of the HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY environment variables otherwise. A nil tlsConfig keeps the
+// system roots and no client certificate.
+func newHTTPClient(timeout time.Duration, proxy *url.URL, pool connPool, tlsConfig *tls.Config) *http.Client {
+	var transport = http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	transport.MaxIdleConns = pool.maxIdle
+	transport.MaxIdleConnsPerHost = pool.maxIdlePerHost
+	transport.IdleConnTimeout = pool.idleTimeout
+
+	return &http.Client{
+		Transport:     transport,
+		CheckRedirect: checkRedirect,
+		Timeout:       timeout,
+	}
+}
+
+// loadTLSConfig builds the TLS configuration of the provider and proxy
+// connections: the CA file is trusted along with the system roots and the
+// client certificate is presented when asked for. It is nil when no file
+// is given.
+func loadTLSConfig(caFile, certFile, keyFile string) (config *tls.Config, err error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("--client-cert and --client-key must be given together")
+	}
+	if caFile == "" && certFile == "" {
+		return nil, nil
+	}
+
+	config = &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA file: %w", err)
+		}
+
+		config.RootCAs, err = x509.SystemCertPool()
+		if err != nil {
+			config.RootCAs = x509.NewCertPool()
+		}
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates in CA file %s", caFile)
+		}
+	}
+
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+// parseProxy parses the proxy address, an empty one means no override.
+func parseProxy(s string) (proxy *url.URL, err error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	proxy, err = url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy '%s': %w", s, err)
+	}
+
+	switch proxy.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy '%s', expected an http, https or socks5 URL", s)
+	}
+
+	if proxy.Host == "" {
+		return nil, fmt.Errorf("invalid proxy '%s', no host", s)
+	}
+
+	return
+}
+
+// resolveTimeout picks the HTTP timeout: flag value if it was set explicitly,
+// then the environment variable, then the default.
+func resolveTimeout(flagValue time.Duration, flagSet bool, env string) (time.Duration, error) {
+	if flagSet {
+		return flagValue, nil
+	}
+
+	if env == "" {
+		return defaultTimeout, nil
+	}
+
+	timeout, err := time.ParseDuration(env)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value '%s': %w", timeoutEnv, env, err)
+	}
+
+	return timeout, nil
+}
+
+func isFlagSet(fs *flag.FlagSet, name string) (set bool) {
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return
+}
+
+// setFlags returns the names of the flags set so far.
+func setFlags(fs *flag.FlagSet) map[string]bool {
+	var names = map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		names[f.Name] = true
+	})
+	return names
+}
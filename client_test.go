@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"currency/currency"
+
+	"golang.org/x/time/rate"
+)
+
+func TestResolveTimeout(t *testing.T) {
+	var tests = []struct {
+		name    string
+		flag    time.Duration
+		flagSet bool
+		env     string
+		want    time.Duration
+	}{
+		{name: "default", want: defaultTimeout},
+		{name: "env", env: "10s", want: 10 * time.Second},
+		{name: "flag", flag: 5 * time.Second, flagSet: true, want: 5 * time.Second},
+		{name: "flag over env", flag: 5 * time.Second, flagSet: true, env: "10s", want: 5 * time.Second},
+		{name: "flag set to the default", flag: defaultTimeout, flagSet: true, env: "10s", want: defaultTimeout},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveTimeout(tt.flag, tt.flagSet, tt.env)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: timeout = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveTimeoutInvalidEnv(t *testing.T) {
+	_, err := resolveTimeout(0, false, "soon")
+	if err == nil {
+		t.Fatal("invalid timeout env accepted")
+	}
+}
+
+func TestNewHTTPClientTimeout(t *testing.T) {
+	var client = newHTTPClient(7*time.Second, nil, defaultConnPool, nil)
+	if client.Timeout != 7*time.Second {
+		t.Errorf("client timeout = %v, want 7s", client.Timeout)
+	}
+}
+
+// failingServer answers with the status to the first failures requests
+// and with an empty XML document after them.
+func failingServer(t *testing.T, status int, failures int32) (srv *httptest.Server, requests *atomic.Int32) {
+	requests = new(atomic.Int32)
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		io.WriteString(w, "<ValCurs/>")
+	}))
+	t.Cleanup(srv.Close)
+	return
+}
+
+func TestProxyFlag(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	// the proxy forwards the requests for the unresolvable CBR host to the
+	// fake CBR
+	var hosts []string
+	var proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.URL.Host)
+		r.URL.Scheme, r.URL.Host = "http", cbr.Listener.Addr().String()
+		res, err := http.Get(r.URL.String())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer res.Body.Close()
+		w.Header().Set("Content-Type", res.Header.Get("Content-Type"))
+		io.Copy(w, res.Body)
+	}))
+	defer proxy.Close()
+	t.Setenv("HTTP_PROXY", "")
+
+	code, out := runCLI(t, "--proxy", proxy.URL, "--url-template", "http://cbr.invalid/scripts/XML_daily.asp?date_req=%s", "--currency", "usd", "--date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	if want := "14.10.2026\tUSD\t92.50\tRUB\t1\n"; out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+	if len(hosts) != 1 || hosts[0] != "cbr.invalid" {
+		t.Errorf("proxied hosts = %q, want [cbr.invalid]", hosts)
+	}
+}
+
+func TestParseProxy(t *testing.T) {
+	for _, s := range []string{"ftp://proxy:21", "http://", "://proxy"} {
+		if _, err := parseProxy(s); err == nil {
+			t.Errorf("parseProxy(%q) = nil error, want an error", s)
+		}
+	}
+
+	proxy, err := parseProxy("socks5://127.0.0.1:1080")
+	if err != nil || proxy.Host != "127.0.0.1:1080" {
+		t.Errorf("parseProxy(socks5) = %v, %v", proxy, err)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	var tests = []struct {
+		name string
+		env  string
+		args []string
+		want string
+	}{
+		{name: "default", want: defaultUserAgent},
+		{name: "env", env: "rates-bot/1.0", want: "rates-bot/1.0"},
+		{name: "flag", args: []string{"--user-agent", "cron-job"}, want: "cron-job"},
+		{name: "flag over env", env: "rates-bot/1.0", args: []string{"--user-agent", "cron-job"}, want: "cron-job"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var agents []string
+			var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				agents = append(agents, r.UserAgent())
+				w.Header().Set("Content-Type", "application/xml; charset=windows-1251")
+				io.WriteString(w, `<ValCurs Date="14.10.2026"><Valute ID="R01235"><NumCode>840</NumCode><CharCode>USD</CharCode><Nominal>1</Nominal><Name>USD</Name><Value>92,5012</Value></Valute></ValCurs>`)
+			}))
+			defer srv.Close()
+			t.Setenv(userAgentEnv, tt.env)
+
+			var args = append([]string{"--url-template", srv.URL + "/?date_req=%s", "--currency", "usd", "--date", "14.10.2026"}, tt.args...)
+			if code, _ := runCLI(t, args...); code != exitOK {
+				t.Fatalf("exit code = %d, want %d", code, exitOK)
+			}
+			if len(agents) != 1 || agents[0] != tt.want {
+				t.Errorf("User-Agent = %q, want %q", agents, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	daily, err := os.ReadFile("testdata/daily.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests atomic.Int32
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Redirect(w, r, "/loop?"+r.URL.RawQuery, http.StatusFound)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Redirect(w, r, "/daily?"+r.URL.RawQuery, http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/maintenance", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Redirect(w, r, "/html", http.StatusFound)
+	})
+	mux.HandleFunc("/daily", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/xml; charset=windows-1251")
+		w.Write(daily)
+	})
+	mux.HandleFunc("/html", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, "<html>down for maintenance</html>")
+	})
+	var srv = httptest.NewServer(mux)
+	defer srv.Close()
+
+	var fetch = func(path string) ([]string, error) {
+		requests.Store(0)
+		var app = newTestApp(t)
+		app.client = newTestClient(newHTTPClient(5*time.Second, nil, defaultConnPool, nil), srv.URL+path+"?date_req=%s")
+		return app.getCurrencyItemCache(context.Background(), "usd", testDate, true)
+	}
+
+	row, err := fetch("/moved")
+	if err != nil || row[2] != "92.50" {
+		t.Errorf("redirected fetch = %v, %v, want the USD row", row, err)
+	}
+
+	// a loop is not retried
+	_, err = fetch("/loop")
+	if !errors.Is(err, currency.ErrTooManyRedirects) {
+		t.Errorf("redirect loop error = %v, want ErrTooManyRedirects", err)
+	}
+	if n := requests.Load(); n != maxRedirects+1 {
+		t.Errorf("redirect loop requests = %d, want %d", n, maxRedirects+1)
+	}
+
+	// a redirect to an HTML page is neither decoded nor retried
+	_, err = fetch("/maintenance")
+	var ce *currency.ContentTypeError
+	if !errors.As(err, &ce) || !strings.HasPrefix(ce.ContentType, "text/html") {
+		t.Errorf("HTML page error = %v, want a ContentTypeError", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("HTML page requests = %d, want 2", n)
+	}
+}
+
+func TestConnectionReuse(t *testing.T) {
+	var dates []time.Time
+	for i := 19; i >= 0; i-- {
+		dates = append(dates, testDate.AddDate(0, 0, -i))
+	}
+	var cbr = newFakeCBR(t, dates...)
+
+	var conns atomic.Int32
+	var srv = httptest.NewUnstartedServer(cbr.Config.Handler)
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	var pool = connPool{maxIdle: 4, maxIdlePerHost: 2, idleTimeout: time.Minute}
+	var httpClient = newHTTPClient(5*time.Second, nil, pool, nil)
+	var transport = httpClient.Transport.(*http.Transport)
+	if transport.MaxIdleConns != 4 || transport.MaxIdleConnsPerHost != 2 || transport.IdleConnTimeout != time.Minute {
+		t.Errorf("transport pool = %d, %d, %v, want 4, 2, 1m0s", transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.IdleConnTimeout)
+	}
+
+	// sequential fetches of a range go over a single connection
+	var app = newTestApp(t)
+	app.client = newTestClient(httpClient, srv.URL+"/?date_req=%s")
+	rows, err := app.collectRows(context.Background(), dates, []string{"usd"}, false, true, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cbr.requests.Load() != int32(len(dates)) || len(rows) != len(dates) {
+		t.Fatalf("%d requests for %d rows, want %d", cbr.requests.Load(), len(rows), len(dates))
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("connections = %d for %d requests, want 1", n, len(dates))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	var dates []time.Time
+	for i := 5; i >= 0; i-- {
+		dates = append(dates, testDate.AddDate(0, 0, -i))
+	}
+	var cbr = newFakeCBR(t, dates...)
+
+	// 20 requests per second shared by the four workers, the first one
+	// without waiting
+	const perSecond = 20
+	var app = newTestApp(t)
+	app.client = newClient(cbr.Client(), cbr.template(), defaultUserAgent, 0, rate.NewLimiter(perSecond, 1), cbrProviderName)
+
+	var start = time.Now()
+	_, err := app.collectRows(context.Background(), dates, []string{"usd"}, false, true, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var elapsed = time.Since(start)
+
+	var min = time.Duration(len(dates)-1) * time.Second / perSecond
+	if n := cbr.requests.Load(); n != int32(len(dates)) {
+		t.Fatalf("requests = %d, want %d", n, len(dates))
+	}
+	if elapsed < min*9/10 || elapsed > min+time.Second {
+		t.Errorf("%d requests at %d per second took %v, want about %v", len(dates), perSecond, elapsed, min)
+	}
+
+	if code, _ := runCLI(t, "--rate-limit", "-1", "--url-template", cbr.template(), "--date", "14.10.2026"); code != exitUsage {
+		t.Errorf("--rate-limit -1 exit code = %d, want %d", code, exitUsage)
+	}
+}
+
+func TestCAFile(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+	var srv = httptest.NewUnstartedServer(cbr.Config.Handler)
+	// the handshake without the CA fails on purpose
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	var ca = writeFile(t, "ca.pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})))
+	var args = []string{"--url-template", srv.URL + "/?date_req=%s", "--retries", "0", "--currency", "usd", "--date", "14.10.2026"}
+
+	// the certificate of the server is only trusted with the CA file
+	if code, _ := runCLI(t, args...); code != exitNetwork {
+		t.Errorf("exit code without --ca-file = %d, want %d", code, exitNetwork)
+	}
+	code, out := runCLI(t, append(args, "--ca-file", ca)...)
+	if code != exitOK {
+		t.Fatalf("exit code with --ca-file = %d, want %d", code, exitOK)
+	}
+	if want := "14.10.2026\tUSD\t92.50\tRUB\t1\n"; out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+
+	for _, tt := range [][]string{
+		{"--ca-file", filepath.Join(t.TempDir(), "missing")},
+		{"--ca-file", writeFile(t, "ca.pem", "not a certificate")},
+		{"--client-cert", ca},
+		{"--client-key", ca},
+	} {
+		if code, _ := runCLI(t, append(args, tt...)...); code == exitOK {
+			t.Errorf("%v: exit code = %d, want a failure", tt, code)
+		}
+	}
+}
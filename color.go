@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+
+	ansiGreen = "\033[32m"
+	ansiRed   = "\033[31m"
+	ansiReset = "\033[0m"
+)
+
+var colorModes = []string{colorAuto, colorAlways, colorNever}
+
+func validateColor(mode string) error {
+	for _, m := range colorModes {
+		if m == mode {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown color mode '%s', expected one of: %v", mode, colorModes)
+}
+
+// useColor reports whether the output to w is colorized in the mode, auto
+// colorizes terminals only.
+func useColor(mode string, w io.Writer) bool {
+	switch mode {
+	case colorAlways:
+		return true
+	case colorAuto:
+		return isTerminal(w)
+	default:
+		return false
+	}
+}
+
+// colorizeChange returns a copy of the rows with increases of the change
+// columns in green and decreases in red.
+func colorizeChange(header []string, rows [][]string) [][]string {
+	var columns []int
+	for i, name := range header {
+		if name == "change" || name == "change_percent" {
+			columns = append(columns, i)
+		}
+	}
+
+	var out = make([][]string, len(rows))
+	for i, row := range rows {
+		out[i] = append([]string(nil), row...)
+		for _, c := range columns {
+			if c < len(row) {
+				out[i][c] = colorizeValue(row[c])
+			}
+		}
+	}
+
+	return out
+}
+
+func colorizeValue(s string) string {
+	switch {
+	case strings.Trim(s, "-0.") == "":
+		// blank or zero change
+		return s
+	case strings.HasPrefix(s, "-"):
+		return ansiRed + s + ansiReset
+	default:
+		return ansiGreen + s + ansiReset
+	}
+}
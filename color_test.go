@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestColorModes(t *testing.T) {
+	var rows = [][]string{
+		{"14.10.2026", "USD", "92.50", "RUB", "1", "2.50", "2.78"},
+		{"14.10.2026", "EUR", "100.12", "RUB", "1", "-0.38", "-0.38"},
+		{"14.10.2026", "JPY", "0.62", "RUB", "100", "0.00", "0.00"},
+	}
+
+	var file, err = os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var tests = []struct {
+		mode string
+		want string
+	}{
+		{
+			mode: colorAlways,
+			want: "14.10.2026\tUSD\t92.50\tRUB\t1\t\033[32m2.50\033[0m\t\033[32m2.78\033[0m\n" +
+				"14.10.2026\tEUR\t100.12\tRUB\t1\t\033[31m-0.38\033[0m\t\033[31m-0.38\033[0m\n" +
+				"14.10.2026\tJPY\t0.62\tRUB\t100\t0.00\t0.00\n",
+		},
+		{
+			mode: colorNever,
+			want: "14.10.2026\tUSD\t92.50\tRUB\t1\t2.50\t2.78\n" +
+				"14.10.2026\tEUR\t100.12\tRUB\t1\t-0.38\t-0.38\n" +
+				"14.10.2026\tJPY\t0.62\tRUB\t100\t0.00\t0.00\n",
+		},
+	}
+
+	var app = &App{mem: newMemoryRates(1), opts: defaultOptions()}
+	app.opts.rows.withChange = true
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		app.opts.colorize = useColor(tt.mode, &buf)
+		if err := app.writeRows(&buf, tsvFormat, rows); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("--color %s output = %q, want %q", tt.mode, buf.String(), tt.want)
+		}
+	}
+
+	// auto colorizes terminals only, neither a pipe nor a file
+	for _, w := range []io.Writer{&bytes.Buffer{}, file, nopWriteCloser{file}} {
+		if useColor(colorAuto, w) {
+			t.Errorf("useColor(auto, %T) = true, want false", w)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"currency/currency"
+)
+
+var compareHeader = []string{"code", "from_date", "from_rate", "to_date", "to_rate", "change", "change_percent"}
+
+// parseCompare parses the two comma-separated dates of --compare.
+func parseCompare(s string, allowFuture bool) (first, second time.Time, err error) {
+	var parts = strings.Split(s, ",")
+	if len(parts) != 2 {
+		return first, second, fmt.Errorf("--compare expects two dates separated by a comma, got '%s'", s)
+	}
+
+	first, err = resolveDate(strings.TrimSpace(parts[0]), 0, allowFuture)
+	if err != nil {
+		return
+	}
+
+	second, err = resolveDate(strings.TrimSpace(parts[1]), 0, allowFuture)
+	return
+}
+
+// compareRows returns a row per currency with its rates per unit on the
+// two dates and the change between them. A date without a rate leaves its
+// columns and the change blank.
+func (a *App) compareRows(ctx context.Context, first, second time.Time, currencies []string, skipCache bool) (rows [][]string, err error) {
+	for _, curr := range currencies {
+		from, fromRate, err := a.compareRate(ctx, curr, first, skipCache)
+		if err != nil {
+			return nil, err
+		}
+
+		to, toRate, err := a.compareRate(ctx, curr, second, skipCache)
+		if err != nil {
+			return nil, err
+		}
+
+		var row = []string{a.opts.rows.caseCode(strings.ToUpper(curr))}
+		row = append(row, from...)
+		row = append(row, to...)
+		if fromRate == nil || toRate == nil {
+			row = append(row, "", "")
+		} else {
+			row = append(row, changeColumns(*toRate, *fromRate, a.opts.rows)...)
+		}
+		rows = append(rows, row)
+	}
+
+	return
+}
+
+// compareRate returns the published date and the rate columns of the
+// currency for t, and the rate unless there is none.
+func (a *App) compareRate(ctx context.Context, name string, t time.Time, skipCache bool) (columns []string, rate *float64, err error) {
+	row, err := a.getCurrencyItemCache(ctx, name, t, skipCache)
+
+	var notFound *CurrencyNotFoundError
+	var notPublished *currency.NotPublishedError
+	if errors.As(err, &notFound) || errors.As(err, &notPublished) {
+		return []string{t.Format(outputDateFormat), ""}, nil, nil
+	}
+	if err != nil {
+		return
+	}
+
+	val, err := a.getCurrencyValueCache(ctx, name, t, skipCache)
+	if err != nil {
+		return
+	}
+
+	return []string{row[0], a.opts.rows.format(val)}, &val, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"currency/currency"
+)
+
+// datedProvider publishes the valutes of the dates it has, by ratesKey,
+// and nothing for the others.
+type datedProvider map[string]stubProvider
+
+func (datedProvider) Name() string {
+	return "stub"
+}
+
+func (datedProvider) Base() string {
+	return rubCurrency
+}
+
+func (datedProvider) URL(client *currency.Client, t time.Time) string {
+	return "stub:" + ratesKey(t)
+}
+
+func (p datedProvider) Rates(ctx context.Context, client *currency.Client, t time.Time, fallback bool, cond currency.Validators) ([]*Valute, time.Time, currency.Validators, error) {
+	valutes, ok := p[ratesKey(t)]
+	if !ok {
+		return nil, t, currency.Validators{}, &currency.NotPublishedError{Date: t, Days: maxFallbackDays}
+	}
+	return valutes.Rates(ctx, client, t, fallback, cond)
+}
+
+func TestCompareRows(t *testing.T) {
+	var first = testDate.AddDate(0, 0, -13)
+	var app = newTestApp(t)
+	app.opts.provider = datedProvider{
+		ratesKey(first): {
+			{CharCode: "USD", Nominal: 1, Value: "90"},
+			{CharCode: "JPY", Nominal: 100, Value: "64"},
+		},
+		ratesKey(testDate): {
+			{CharCode: "USD", Nominal: 1, Value: "92,5"},
+			{CharCode: "JPY", Nominal: 100, Value: "62,4"},
+		},
+	}
+
+	var tests = []struct {
+		name          string
+		first, second time.Time
+		want          [][]string
+	}{
+		{
+			// 2.50 / 90 and -0.016 / 0.64
+			name: "known pair", first: first, second: testDate,
+			want: [][]string{
+				{"USD", "01.10.2026", "90.00", "14.10.2026", "92.50", "2.50", "2.78"},
+				{"JPY", "01.10.2026", "0.64", "14.10.2026", "0.62", "-0.02", "-2.50"},
+			},
+		},
+		{
+			name: "reversed", first: testDate, second: first,
+			want: [][]string{
+				{"USD", "14.10.2026", "92.50", "01.10.2026", "90.00", "-2.50", "-2.70"},
+				{"JPY", "14.10.2026", "0.62", "01.10.2026", "0.64", "0.02", "2.56"},
+			},
+		},
+		{
+			// no rates were published on the 5th
+			name: "missing date", first: testDate.AddDate(0, 0, -9), second: testDate,
+			want: [][]string{
+				{"USD", "05.10.2026", "", "14.10.2026", "92.50", "", ""},
+				{"JPY", "05.10.2026", "", "14.10.2026", "0.62", "", ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		rows, err := app.compareRows(context.Background(), tt.first, tt.second, []string{"usd", "jpy"}, false)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(rows, tt.want) {
+			t.Errorf("%s: rows = %v, want %v", tt.name, rows, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds defaults read from the config file. Empty fields keep the
+// built-in defaults.
+type Config struct {
+	Currency  string `yaml:"currency"`
+	Format    string `yaml:"format"`
+	Precision *int   `yaml:"precision"`
+	Timeout   string `yaml:"timeout"`
+	CachePath string `yaml:"cache-path"`
+}
+
+// defaultConfigPath is the config file under the user config directory,
+// $XDG_CONFIG_HOME or $HOME/.config on Linux. It is empty when neither is
+// set, as in many containers, and no default config is read then.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "currency", "config.yaml")
+}
+
+// loadConfig reads the config file. A missing file is not an error unless
+// its path was given explicitly.
+func loadConfig(path string, explicit bool) (cfg Config, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return cfg, nil
+	}
+	if err != nil {
+		return
+	}
+
+	err = yaml.Unmarshal(data, &cfg)
+	if err != nil {
+		err = fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return
+}
+
+// configValue is a config field bound to its flag and environment
+// variable.
+type configValue struct {
+	flag  string
+	env   string
+	value string
+}
+
+func (c Config) values() []configValue {
+	var precision string
+	if c.Precision != nil {
+		precision = strconv.Itoa(*c.Precision)
+	}
+
+	return []configValue{
+		{flag: "currency", value: c.Currency},
+		{flag: "format", value: c.Format},
+		{flag: "precision", value: precision},
+		{flag: "timeout", env: timeoutEnv, value: c.Timeout},
+		{flag: "cache-path", env: cachePathEnv, value: c.CachePath},
+	}
+}
+
+// apply sets the flags from the config. Values given on the command line
+// or through the environment take precedence and are left untouched.
+func (c Config) apply(fs *flag.FlagSet) error {
+	for _, v := range c.values() {
+		if v.value == "" || isFlagSet(fs, v.flag) || (v.env != "" && os.Getenv(v.env) != "") {
+			continue
+		}
+
+		err := fs.Set(v.flag, v.value)
+		if err != nil {
+			return fmt.Errorf("invalid config value for %s: %w", v.flag, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes the content to a file in a temporary directory and
+// returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	var path = filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	var path = writeFile(t, "config.yaml", "currency: eur,jpy\nformat: csv\nprecision: 4\ntimeout: 5s\ncache-path: /tmp/rates\n")
+
+	cfg, err := loadConfig(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var precision = 4
+	var want = Config{Currency: "eur,jpy", Format: "csv", Precision: &precision, Timeout: "5s", CachePath: "/tmp/rates"}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("config = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestDefaultConfigPath(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the user config directory comes from XDG_CONFIG_HOME on Linux only")
+	}
+
+	var home, xdg = t.TempDir(), t.TempDir()
+	var tests = []struct {
+		name, home, xdg, want string
+	}{
+		{name: "config home", home: home, xdg: xdg, want: filepath.Join(xdg, "currency", "config.yaml")},
+		{name: "home", home: home, want: filepath.Join(home, ".config", "currency", "config.yaml")},
+		// not a path relative to the current directory
+		{name: "neither"},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("HOME", tt.home)
+		t.Setenv("XDG_CONFIG_HOME", tt.xdg)
+		if got := defaultConfigPath(); got != tt.want {
+			t.Errorf("%s: path = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	var missing = filepath.Join(t.TempDir(), "config.yaml")
+
+	cfg, err := loadConfig(missing, false)
+	if err != nil || !reflect.DeepEqual(cfg, Config{}) {
+		t.Errorf("missing default config = %+v, %v, want no config and no error", cfg, err)
+	}
+
+	_, err = loadConfig(missing, true)
+	if err == nil {
+		t.Error("missing --config accepted")
+	}
+
+	_, err = loadConfig(writeFile(t, "config.yaml", "precision: two\n"), true)
+	if err == nil || !strings.Contains(err.Error(), "invalid config") {
+		t.Errorf("malformed config error = %v", err)
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	var fs = flag.NewFlagSet("currency", flag.ContinueOnError)
+	var (
+		currency  = fs.String("currency", usdCurrency, "")
+		format    = fs.String("format", tsvFormat, "")
+		precision = fs.Int("precision", defaultPrecision, "")
+		timeout   = fs.Duration("timeout", defaultTimeout, "")
+		cacheFile = fs.String("cache-path", "", "")
+	)
+	err := fs.Parse([]string{"--format", jsonFormat})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(cachePathEnv, "/tmp/env")
+
+	var four = 4
+	err = Config{Currency: "eur", Format: csvFormat, Precision: &four, Timeout: "5s", CachePath: "/tmp/config"}.apply(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the command line and the environment win over the config
+	if *currency != "eur" || *format != jsonFormat || *precision != 4 || *timeout != 5*time.Second || *cacheFile != "" {
+		t.Errorf("resolved currency %s, format %s, precision %d, timeout %v, cache path %q",
+			*currency, *format, *precision, *timeout, *cacheFile)
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+	var cfg = writeFile(t, "config.yaml", "currency: eur\nprecision: 4\n")
+
+	var tests = []struct {
+		args []string
+		out  string
+	}{
+		{out: "14.10.2026\tEUR\t100.1234\tRUB\t1\n"},
+		{args: []string{"--currency", "usd", "--precision", "1"}, out: "14.10.2026\tUSD\t92.5\tRUB\t1\n"},
+		{args: []string{"--format", csvFormat}, out: "14.10.2026,EUR,100.1234,RUB,1\n"},
+		// the config currency and precision are defaults, not flags
+		{args: []string{"--all", "--precision", "2"}, out: "14.10.2026\tUSD\t92.50\tRUB\t1\n14.10.2026\tEUR\t100.12\tRUB\t1\n14.10.2026\tJPY\t0.62\tRUB\t100\n"},
+		{args: []string{"--sig-figs", "3"}, out: "14.10.2026\tEUR\t100\tRUB\t1\n"},
+		{args: []string{"--currencies-file", writeFile(t, "codes", "jpy\n")}, out: "14.10.2026\tJPY\t0.6235\tRUB\t100\n"},
+	}
+
+	for _, tt := range tests {
+		var args = append([]string{"--config", cfg, "--url-template", cbr.template(), "--date", "14.10.2026"}, tt.args...)
+		code, out := runCLI(t, args...)
+		if code != exitOK || out != tt.out {
+			t.Errorf("%v: exit code %d, output %q, want %q", tt.args, code, out, tt.out)
+		}
+	}
+}
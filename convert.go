@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+func (a *App) convert(ctx context.Context, from, to string, amount float64, t time.Time, skipCache bool) (out float64, err error) {
+	fromRate, err := a.getCurrencyValueCache(ctx, from, t, skipCache)
+	if err != nil {
+		return
+	}
+
+	toRate, err := a.getCurrencyValueCache(ctx, to, t, skipCache)
+	if err != nil {
+		return
+	}
+
+	return amount * fromRate / toRate, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	var tests = []struct {
+		from, to string
+		amount   float64
+		want     float64
+	}{
+		{from: "usd", to: "eur", amount: 100, want: 100 * 92.5012 / 100.1234},
+		{from: "USD", to: "rub", amount: 2, want: 185.0024},
+		{from: "rub", to: "usd", amount: 92.5012, want: 1},
+		{from: "rub", to: "rub", amount: 5, want: 5},
+		{from: "jpy", to: "rub", amount: 1000, want: 623.456},
+		{from: "840", to: "978", amount: 1, want: 92.5012 / 100.1234},
+	}
+
+	var app = newTestApp(t)
+	app.opts.provider = testProvider
+	for _, tt := range tests {
+		got, err := app.convert(context.Background(), tt.from, tt.to, tt.amount, testDate, false)
+		if err != nil {
+			t.Errorf("convert(%s, %s, %v): %v", tt.from, tt.to, tt.amount, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("convert(%s, %s, %v) = %v, want %v", tt.from, tt.to, tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestConvertUnknownCurrency(t *testing.T) {
+	var app = newTestApp(t)
+	app.opts.provider = testProvider
+
+	_, err := app.convert(context.Background(), "usd", "xxx", 1, testDate, false)
+	var notFound *CurrencyNotFoundError
+	if !errors.As(err, &notFound) || notFound.Code != "xxx" {
+		t.Fatalf("convert to xxx error = %v, want CurrencyNotFoundError for xxx", err)
+	}
+}
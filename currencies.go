@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// validateCode checks that code looks like an ISO 4217 alphabetic or
+// numeric code, so obvious typos fail before any request is made.
+func validateCode(code string) error {
+	if len(code) != 3 {
+		return fmt.Errorf("invalid currency code '%s', expected three letters or digits", code)
+	}
+
+	if isNumericCode(code) {
+		return nil
+	}
+
+	for _, c := range code {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return fmt.Errorf("invalid currency code '%s', expected three letters or digits", code)
+		}
+	}
+
+	return nil
+}
+
+func isNumericCode(code string) bool {
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return code != ""
+}
+
+// dedupCodes lower-cases the codes and drops repeated ones, keeping the
+// first-seen order.
+func dedupCodes(codes []string) (out []string) {
+	var seen = map[string]bool{}
+	for _, code := range codes {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if seen[code] {
+			continue
+		}
+
+		seen[code] = true
+		out = append(out, code)
+	}
+	return
+}
+
+// requestedCurrencies merges the comma-separated codes with the codes of the
+// file, the default codes are dropped when only the file is given. Aliases
+// are resolved with the options.
+func (o options) requestedCurrencies(list string, listSet bool, path string) (codes []string, err error) {
+	var all []string
+	if path == "" || listSet {
+		all = strings.Split(list, ",")
+	}
+
+	if path != "" {
+		fileCodes, err := readCurrenciesFile(path)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, fileCodes...)
+	}
+
+	for i := range all {
+		all[i] = o.resolveAlias(all[i])
+	}
+
+	codes = dedupCodes(all)
+	if len(codes) == 0 {
+		return nil, errors.New("select at least one currency")
+	}
+
+	for _, code := range codes {
+		err = validateCode(code)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return
+}
+
+// readCurrenciesFile reads currency codes from a file with a code per line.
+// Blank lines and everything after a '#' are ignored.
+func readCurrenciesFile(path string) (codes []string, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read currencies file: %w", err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		codes = append(codes, line)
+	}
+
+	return
+}
+
+// readCurrencyMap reads a file of alias=code lines mapping custom symbols
+// to currency codes. Blank lines and everything after a '#' are ignored.
+func readCurrencyMap(path string) (aliases map[string]string, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read currency map: %w", err)
+	}
+
+	aliases = map[string]string{}
+	for i, line := range strings.Split(string(data), "\n") {
+		if j := strings.IndexByte(line, '#'); j >= 0 {
+			line = line[:j]
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		alias, code, ok := strings.Cut(line, "=")
+		alias = strings.ToLower(strings.TrimSpace(alias))
+		code = strings.ToLower(strings.TrimSpace(code))
+		if !ok || alias == "" {
+			return nil, fmt.Errorf("currency map line %d: expected alias=code, got '%s'", i+1, line)
+		}
+
+		err = validateCode(code)
+		if err != nil {
+			return nil, fmt.Errorf("currency map line %d: %w", i+1, err)
+		}
+		aliases[alias] = code
+	}
+
+	return
+}
+
+// resolveAlias returns the currency code of a --currency-map alias, other
+// codes pass through unchanged.
+func (o options) resolveAlias(code string) string {
+	if mapped, ok := o.aliases[strings.ToLower(strings.TrimSpace(code))]; ok {
+		return mapped
+	}
+	return code
+}
+
+const (
+	codeCaseUpper = "upper"
+	codeCaseLower = "lower"
+	// codeCaseAsIs keeps the currency codes as the provider publishes
+	// them, the base is upper-case.
+	codeCaseAsIs = "as-is"
+)
+
+func validateCodeCase(mode string) error {
+	switch mode {
+	case codeCaseUpper, codeCaseLower, codeCaseAsIs:
+		return nil
+	}
+	return fmt.Errorf("unknown code case '%s', expected %s, %s or %s", mode, codeCaseUpper, codeCaseLower, codeCaseAsIs)
+}
+
+// caseCode applies the --code-case of the options to an emitted code.
+func (o rowOptions) caseCode(code string) string {
+	switch o.codeCase {
+	case codeCaseLower:
+		return strings.ToLower(code)
+	case codeCaseAsIs:
+		return code
+	default:
+		return strings.ToUpper(code)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidateCode(t *testing.T) {
+	var tests = []struct {
+		code  string
+		valid bool
+	}{
+		{code: "usd", valid: true},
+		{code: "EUR", valid: true},
+		{code: "XDR", valid: true},
+		{code: "840", valid: true},
+		{code: ""},
+		{code: "us"},
+		{code: "usdx"},
+		{code: "us1"},
+		{code: "u-d"},
+		{code: "8400"},
+		{code: "дол"},
+	}
+
+	for _, tt := range tests {
+		err := validateCode(tt.code)
+		if tt.valid && err != nil {
+			t.Errorf("validateCode(%q): %v", tt.code, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("validateCode(%q) = nil, want an error", tt.code)
+		}
+	}
+}
+
+func TestDedupCodes(t *testing.T) {
+	var tests = []struct {
+		codes []string
+		want  []string
+	}{
+		{codes: []string{"usd", "usd", "eur"}, want: []string{"usd", "eur"}},
+		{codes: []string{"USD", "usd"}, want: []string{"usd"}},
+		{codes: []string{"jpy", "EUR", " usd", "Eur", "JPY"}, want: []string{"jpy", "eur", "usd"}},
+	}
+
+	for _, tt := range tests {
+		if got := dedupCodes(tt.codes); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("dedupCodes(%q) = %q, want %q", tt.codes, got, tt.want)
+		}
+	}
+}
+
+func TestDuplicateCurrencyRows(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	code, out := runCLI(t, "--url-template", cbr.template(), "--currency", "USD,eur,usd", "--date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	if want := "14.10.2026\tUSD\t92.50\tRUB\t1\n14.10.2026\tEUR\t100.12\tRUB\t1\n"; out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
+
+func TestReadCurrenciesFile(t *testing.T) {
+	var path = writeFile(t, "currencies", "# rates of the week\nusd\n\n  EUR  # euro\r\n\t\n840\n#jpy\n")
+
+	codes, err := readCurrenciesFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"usd", "EUR", "840"}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("codes = %q, want %q", codes, want)
+	}
+
+	// the file is merged with an explicit --currency
+	codes, err = defaultOptions().requestedCurrencies("jpy,usd", true, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"jpy", "usd", "eur", "840"}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("merged codes = %q, want %q", codes, want)
+	}
+
+	if _, err := readCurrenciesFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("missing currencies file accepted")
+	}
+}
+
+func TestCurrencyMap(t *testing.T) {
+	var path = writeFile(t, "aliases", "# symbols of the scripts\ndollar = USD\n\nEuro=eur # common currency\r\nyen=392\n")
+
+	aliases, err := readCurrencyMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"dollar": "usd", "euro": "eur", "yen": "392"}; !reflect.DeepEqual(aliases, want) {
+		t.Errorf("aliases = %v, want %v", aliases, want)
+	}
+
+	var o = defaultOptions()
+	o.aliases = aliases
+	for code, want := range map[string]string{
+		"dollar":  "usd",
+		" EURO ":  "eur",
+		"yen":     "392",
+		"usd":     "usd",
+		"JPY":     "JPY",
+		"unknown": "unknown",
+	} {
+		if got := o.resolveAlias(code); got != want {
+			t.Errorf("resolveAlias(%q) = %q, want %q", code, got, want)
+		}
+	}
+
+	for _, content := range []string{"dollar\n", "=usd\n", "dollar=us dollar\n"} {
+		if _, err := readCurrencyMap(writeFile(t, "aliases", content)); err == nil {
+			t.Errorf("currency map %q accepted", content)
+		}
+	}
+
+	// aliases and codes are both looked up
+	var cbr = newFakeCBR(t, testDate)
+	code, out := runCLI(t, "--url-template", cbr.template(), "--currency-map", path, "--currency", "dollar,jpy,euro", "--date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	if want := "14.10.2026\tUSD\t92.50\tRUB\t1\n14.10.2026\tJPY\t0.62\tRUB\t100\n14.10.2026\tEUR\t100.12\tRUB\t1\n"; out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
+
+func TestCodeCase(t *testing.T) {
+	// a provider publishing a mixed-case code
+	var v = Valute{CharCode: "Usd", Nominal: 1, Value: "92,5012", Date: testDate, Base: rubCurrency}
+
+	var tests = []struct {
+		mode       string
+		code, base string
+	}{
+		{mode: codeCaseUpper, code: "USD", base: "RUB"},
+		{mode: codeCaseLower, code: "usd", base: "rub"},
+		// the base is upper-case
+		{mode: codeCaseAsIs, code: "Usd", base: "RUB"},
+	}
+
+	for _, tt := range tests {
+		if err := validateCodeCase(tt.mode); err != nil {
+			t.Errorf("%s: %v", tt.mode, err)
+		}
+
+		row, err := v.getRow(rowOptions{precision: defaultPrecision, codeCase: tt.mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row[1] != tt.code || row[3] != tt.base {
+			t.Errorf("%s: code and base = %s and %s, want %s and %s", tt.mode, row[1], row[3], tt.code, tt.base)
+		}
+	}
+
+	for _, mode := range []string{"", "Upper", "title"} {
+		if err := validateCodeCase(mode); err == nil {
+			t.Errorf("code case %q accepted", mode)
+		}
+	}
+}
@@ -0,0 +1,455 @@
+package currency
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRetries is the number of retries of DefaultClient.
+	DefaultRetries = 3
+	// DefaultTimeout bounds every request of DefaultClient.
+	DefaultTimeout = time.Second * 2
+	// DefaultUserAgent is the User-Agent header of DefaultClient, the
+	// browser one the command line sends.
+	DefaultUserAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Safari/537.36"
+	// DefaultRetryBackoff is the delay before the first retry when
+	// Client.RetryBackoff is not set, doubled for each next one.
+	DefaultRetryBackoff = time.Millisecond * 200
+	// MaxFallbackDays is how far back Published walks from the requested
+	// date to the nearest day with published rates.
+	MaxFallbackDays = 7
+
+	bodySnippetLength = 80
+	// drainLimit is how much of an unread body is discarded on close so
+	// that the connection can be reused
+	drainLimit = 64 << 10
+)
+
+// StatusError is a response with a status other than 200 OK.
+type StatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status code error: %s", e.Status)
+}
+
+// ContentTypeError is a successful response which is not an XML or JSON
+// document, such as the error page of a mirror.
+type ContentTypeError struct {
+	ContentType string
+	// Snippet is the start of the body
+	Snippet string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("unexpected content type %s; body starts with %q", e.ContentType, e.Snippet)
+}
+
+// NetworkError is returned when the server could not be reached or
+// answered with an error status.
+type NetworkError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("request failed after %d attempt(s): %v", e.Attempts, e.Err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// NotPublishedError is returned when no rates were published for the date
+// nor for the days before it the fallback walks back.
+type NotPublishedError struct {
+	Date time.Time
+	Days int
+}
+
+func (e *NotPublishedError) Error() string {
+	return fmt.Sprintf("no rates published within %d days before %s", e.Days, e.Date.Format(PublishedFormat))
+}
+
+// Validators are the HTTP cache validators of a response. Passed to a
+// request of the same URL they make it conditional.
+type Validators struct {
+	URL          string
+	ETag         string
+	LastModified string
+}
+
+// Client fetches the rates documents of CBR.
+type Client struct {
+	// HTTPClient makes the requests, http.DefaultClient when nil.
+	HTTPClient *http.Client
+	// UserAgent is the User-Agent header of the requests when set.
+	UserAgent string
+	// URLTemplate is the address of the daily rates with one %s for the
+	// date in DateFormat, URLTemplate when empty.
+	URLTemplate string
+	// Retries is how many times a network error or a 5xx response is
+	// retried.
+	Retries int
+	// RetryBackoff is the delay before the first retry, DefaultRetryBackoff
+	// when zero.
+	RetryBackoff time.Duration
+	// Wait is called before every attempt when set, a rate limiter.
+	Wait func(ctx context.Context) error
+	// OnRequest is called before every attempt when set.
+	OnRequest func(url string)
+	// OnRetry is called when set before a failed attempt is retried.
+	OnRetry func(url string, attempt int, err error, delay time.Duration)
+}
+
+// DefaultClient is the client used by Fetch. Unlike http.DefaultClient
+// its requests time out, so a call with a context without deadline cannot
+// hang.
+var DefaultClient = &Client{
+	HTTPClient: &http.Client{Timeout: DefaultTimeout},
+	UserAgent:  DefaultUserAgent,
+	Retries:    DefaultRetries,
+}
+
+// retryable reports whether a failed attempt is worth repeating: network
+// errors and 5xx responses are, 4xx, non-document responses and redirect loops
+// are not.
+func retryable(err error) bool {
+	if errors.Is(err, ErrTooManyRedirects) || errors.Is(err, ErrNotModified) {
+		return false
+	}
+
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Code >= http.StatusInternalServerError
+	}
+	var ce *ContentTypeError
+	return !errors.As(err, &ce)
+}
+
+// bodySnippet reads the start of the body for diagnostics.
+func bodySnippet(body io.Reader) string {
+	var buf = make([]byte, bodySnippetLength)
+	n, _ := io.ReadFull(body, buf)
+	return strings.TrimSpace(strings.ToValidUTF8(string(buf[:n]), ""))
+}
+
+// decodeBody replaces a gzip or deflate encoded body of the response with
+// the decompressed one.
+func decodeBody(res *http.Response) (err error) {
+	var r io.ReadCloser
+	switch strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(res.Body)
+	case "deflate":
+		r, err = zlib.NewReader(res.Body)
+	default:
+		return fmt.Errorf("unsupported content encoding %s", res.Header.Get("Content-Encoding"))
+	}
+	if err != nil {
+		return fmt.Errorf("cannot decompress the response: %w", err)
+	}
+
+	res.Body = decodedBody{ReadCloser: r, raw: res.Body}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+	return nil
+}
+
+// decodedBody closes the decompressor and the raw body.
+type decodedBody struct {
+	io.ReadCloser
+	raw io.ReadCloser
+}
+
+func (b decodedBody) Close() error {
+	b.ReadCloser.Close()
+	return b.raw.Close()
+}
+
+// drainingBody discards what is left of the body on close, a connection
+// is only reused once its response was read to the end.
+type drainingBody struct {
+	io.ReadCloser
+}
+
+func (b drainingBody) Close() error {
+	io.CopyN(io.Discard, b.ReadCloser, drainLimit)
+	return b.ReadCloser.Close()
+}
+
+// isDocument reports whether the content type is an XML or JSON one, the
+// JSON mirror serves it as JavaScript. Responses without a content type
+// are given the benefit of the doubt.
+func isDocument(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/xml", "text/xml", "application/json", "application/javascript", "text/javascript":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+xml") || strings.HasSuffix(mediaType, "+json")
+}
+
+// do makes a single attempt of a GET request, conditional on cond when it
+// is for the same URL.
+func (c *Client) do(ctx context.Context, url string, cond Validators) (res *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	// set explicitly, the transport leaves the body compressed and
+	// decodeBody decompresses it
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+
+	if cond.URL == url {
+		if cond.ETag != "" {
+			req.Header.Set("If-None-Match", cond.ETag)
+		}
+		if cond.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cond.LastModified)
+		}
+	}
+
+	// every attempt of every worker waits its turn
+	if c.Wait != nil {
+		err = c.Wait(ctx)
+		if err != nil {
+			return
+		}
+	}
+
+	if c.OnRequest != nil {
+		c.OnRequest(url)
+	}
+
+	var client = c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err = client.Do(req)
+	if err != nil {
+		return
+	}
+
+	if res.Body == nil {
+		return nil, ErrEmptyResponse
+	}
+
+	if res.StatusCode == http.StatusNotModified {
+		res.Body.Close()
+		return nil, ErrNotModified
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, &StatusError{Code: res.StatusCode, Status: res.Status}
+	}
+
+	res.Body = drainingBody{res.Body}
+
+	err = decodeBody(res)
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+
+	if contentType := res.Header.Get("Content-Type"); !isDocument(contentType) {
+		defer res.Body.Close()
+		return nil, &ContentTypeError{ContentType: contentType, Snippet: bodySnippet(res.Body)}
+	}
+
+	return
+}
+
+// Get performs a GET request, retrying network errors and 5xx responses
+// with exponential backoff, and returns the validators of the response.
+// The request is conditional on cond when it is for the same URL, a 304
+// response is ErrNotModified. Failures are a *NetworkError. The caller must
+// close the response body.
+func (c *Client) Get(ctx context.Context, url string, cond Validators) (res *http.Response, v Validators, err error) {
+	var delay = c.RetryBackoff
+	if delay <= 0 {
+		delay = DefaultRetryBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		res, err = c.do(ctx, url, cond)
+		if err == nil {
+			v = Validators{URL: url, ETag: res.Header.Get("ETag"), LastModified: res.Header.Get("Last-Modified")}
+			return
+		}
+
+		if attempt > c.Retries || !retryable(err) || ctx.Err() != nil {
+			return nil, v, &NetworkError{Attempts: attempt, Err: err}
+		}
+
+		if c.OnRetry != nil {
+			c.OnRetry(url, attempt, err, delay)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, v, &NetworkError{Attempts: attempt, Err: ctx.Err()}
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// DayURL returns the address of the daily rates for the date.
+func (c *Client) DayURL(date time.Time) string {
+	var tmpl = c.URLTemplate
+	if tmpl == "" {
+		tmpl = URLTemplate
+	}
+	return fmt.Sprintf(tmpl, date.Format(DateFormat))
+}
+
+// Daily fetches the daily rates document for the date in a single request,
+// conditional on cond. On weekends and holidays CBR answers with no rates
+// or with those of another day.
+func (c *Client) Daily(ctx context.Context, date time.Time, cond Validators) (v ValCurs, got Validators, err error) {
+	var url = c.DayURL(date)
+	res, got, err := c.Get(ctx, url, cond)
+	if err != nil {
+		return
+	}
+
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return v, got, fmt.Errorf("cannot read rates for %s from %s: %w", date.Format(PublishedFormat), url, err)
+	}
+
+	v, err = Decode(body)
+	if err != nil && !errors.Is(err, ErrEmptyResponse) {
+		err = fmt.Errorf("cannot decode rates for %s from %s: %w", date.Format(PublishedFormat), url, err)
+	}
+	return
+}
+
+// PublishedFor reports whether v holds the rates set for the date.
+func (v ValCurs) PublishedFor(date time.Time) bool {
+	return len(v.Valutes) > 0 && v.Date == date.Format(PublishedFormat)
+}
+
+// publishedWithin reports whether v holds rates set for a day from oldest
+// to the date t.
+func (v ValCurs) publishedWithin(oldest, t time.Time) bool {
+	if len(v.Valutes) == 0 {
+		return false
+	}
+
+	published, err := v.PublishedDate(t.Location())
+	if err != nil {
+		return false
+	}
+	return !published.Before(oldest) && !published.After(t)
+}
+
+// Published fetches the rates published for the date, walking back up to
+// MaxFallbackDays to the nearest day with published rates. The rates of
+// an earlier day CBR answers with on weekends and holidays are taken as
+// they are when within MaxFallbackDays, saving a request per day walked
+// back. The request of the date is conditional on cond, the validators are
+// those of the response of the returned day.
+func (c *Client) Published(ctx context.Context, date time.Time, cond Validators) (v ValCurs, got Validators, err error) {
+	var oldest = time.Date(date.Year(), date.Month(), date.Day()-MaxFallbackDays, 0, 0, 0, 0, date.Location())
+	var t = date
+	for days := 0; ; days++ {
+		v, got, err = c.Daily(ctx, t, cond)
+		if err != nil || v.publishedWithin(oldest, t) {
+			return
+		}
+
+		if days >= MaxFallbackDays {
+			return v, got, &NotPublishedError{Date: date, Days: MaxFallbackDays}
+		}
+		t = t.AddDate(0, 0, -1)
+	}
+}
+
+// Day fetches the rates published for the date or the nearest day before.
+func (c *Client) Day(ctx context.Context, date time.Time) (v ValCurs, err error) {
+	v, _, err = c.Published(ctx, date, Validators{})
+	return
+}
+
+// History fetches the rate history of the currency with the CBR
+// identifier id published from the date to the date.
+func (c *Client) History(ctx context.Context, id string, from, to time.Time) (records []Record, err error) {
+	var url = fmt.Sprintf(DynamicURLTemplate, from.Format(DateFormat), to.Format(DateFormat), id)
+	res, _, err := c.Get(ctx, url, Validators{})
+	if err != nil {
+		return
+	}
+
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read rate history of %s from %s: %w", id, url, err)
+	}
+
+	v, err := DecodeDynamic(body)
+	if err != nil && !errors.Is(err, ErrEmptyResponse) {
+		err = fmt.Errorf("cannot decode rate history of %s from %s: %w", id, url, err)
+	}
+	return v.Records, err
+}
+
+// Fetch returns the rate of the currency code published for the date, or
+// for the nearest day before it.
+func (c *Client) Fetch(ctx context.Context, code string, date time.Time) (rate Rate, err error) {
+	v, err := c.Day(ctx, date)
+	if err != nil {
+		return
+	}
+
+	rates, err := v.Rates(date.Location())
+	if err != nil {
+		return
+	}
+
+	for _, r := range rates {
+		if strings.EqualFold(r.Code, code) {
+			return r, nil
+		}
+	}
+
+	err = fmt.Errorf("%w: '%s' on %s", ErrNotFound, code, date.Format(PublishedFormat))
+	return
+}
+
+// Fetch returns the rate of the currency code published for the date, or
+// for the nearest day before it, using DefaultClient.
+func Fetch(ctx context.Context, code string, date time.Time) (Rate, error) {
+	return DefaultClient.Fetch(ctx, code, date)
+}
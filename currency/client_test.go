@@ -0,0 +1,354 @@
+package currency
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testDate is a Wednesday with published rates.
+var testDate = time.Date(2026, time.October, 14, 0, 0, 0, 0, time.UTC)
+
+const testDaily = `<?xml version="1.0" encoding="windows-1251"?>` +
+	`<ValCurs Date="%s" name="Foreign Currency Market">` +
+	`<Valute ID="R01235"><NumCode>840</NumCode><CharCode>USD</CharCode><Nominal>1</Nominal><Name>US Dollar</Name><Value>92,5012</Value></Valute>` +
+	`<Valute ID="R01820"><NumCode>392</NumCode><CharCode>JPY</CharCode><Nominal>100</Nominal><Name>Yen</Name><Value>62,3456</Value></Valute>` +
+	`</ValCurs>`
+
+// newTestServer serves the daily rates of the published dates, dated as
+// requested, and an empty document for the other dates. The client
+// requests them without retry delays.
+func newTestServer(t *testing.T, published ...time.Time) (client *Client, requests *atomic.Int32) {
+	var dates = map[string]bool{}
+	for _, d := range published {
+		dates[d.Format(DateFormat)] = true
+	}
+
+	requests = new(atomic.Int32)
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/xml; charset=windows-1251")
+
+		var date = r.URL.Query().Get("date_req")
+		if !dates[date] {
+			io.WriteString(w, `<ValCurs Date="01.01.2000" name="Foreign Currency Market"></ValCurs>`)
+			return
+		}
+		io.WriteString(w, strings.Replace(testDaily, "%s", strings.Replace(date, "/", ".", -1), 1))
+	}))
+	t.Cleanup(srv.Close)
+
+	return &Client{
+		HTTPClient:   srv.Client(),
+		URLTemplate:  srv.URL + "/scripts/XML_daily.asp?date_req=%s",
+		RetryBackoff: time.Millisecond,
+	}, requests
+}
+
+// failingServer answers with the status to the first failures requests
+// and with an empty document after them.
+func failingServer(t *testing.T, status int, failures int32) (client *Client, url string, requests *atomic.Int32) {
+	requests = new(atomic.Int32)
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		io.WriteString(w, "<ValCurs/>")
+	}))
+	t.Cleanup(srv.Close)
+
+	return &Client{HTTPClient: srv.Client(), RetryBackoff: time.Millisecond}, srv.URL, requests
+}
+
+func TestFetch(t *testing.T) {
+	client, _ := newTestServer(t, testDate)
+
+	rate, err := client.Fetch(context.Background(), "jpy", testDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want = Rate{Date: testDate, Code: "JPY", Name: "Yen", Value: 62.3456, Nominal: 100}
+	if rate != want {
+		t.Errorf("rate = %+v, want %+v", rate, want)
+	}
+	if got, want := rate.String(), "14.10.2026\tJPY\t0.62"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestFetchFallback(t *testing.T) {
+	var friday = testDate.AddDate(0, 0, -5)
+	client, requests := newTestServer(t, friday)
+
+	rate, err := client.Fetch(context.Background(), "usd", testDate.AddDate(0, 0, -3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rate.Date.Equal(friday) {
+		t.Errorf("date = %v, want the fallback to %v", rate.Date, friday)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("requests = %d, want 3", n)
+	}
+}
+
+func TestFetchPreviousDocument(t *testing.T) {
+	// CBR answers the weekend and holiday dates with the rates of the
+	// previous published day
+	var friday = testDate.AddDate(0, 0, -5)
+	var requests atomic.Int32
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		io.WriteString(w, strings.Replace(testDaily, "%s", friday.Format(PublishedFormat), 1))
+	}))
+	t.Cleanup(srv.Close)
+	var client = &Client{HTTPClient: srv.Client(), URLTemplate: srv.URL + "/scripts/XML_daily.asp?date_req=%s"}
+
+	var tests = []struct {
+		date     time.Time
+		requests int32
+		err      bool
+	}{
+		{date: friday.AddDate(0, 0, 2), requests: 1},
+		{date: friday.AddDate(0, 0, MaxFallbackDays), requests: 1},
+		// too old for the date
+		{date: friday.AddDate(0, 0, MaxFallbackDays+1), requests: MaxFallbackDays + 1, err: true},
+	}
+
+	for _, tt := range tests {
+		requests.Store(0)
+		rate, err := client.Fetch(context.Background(), "usd", tt.date)
+		switch {
+		case tt.err && err == nil:
+			t.Errorf("%s: rate = %+v, want an error", tt.date.Format(PublishedFormat), rate)
+		case !tt.err && (err != nil || !rate.Date.Equal(friday)):
+			t.Errorf("%s: rate = %+v, %v, want the rate of %s", tt.date.Format(PublishedFormat), rate, err, friday.Format(PublishedFormat))
+		}
+		if n := requests.Load(); n != tt.requests {
+			t.Errorf("%s: requests = %d, want %d", tt.date.Format(PublishedFormat), n, tt.requests)
+		}
+	}
+}
+
+func TestDefaultClient(t *testing.T) {
+	if DefaultClient.HTTPClient == nil || DefaultClient.HTTPClient.Timeout <= 0 {
+		t.Fatal("DefaultClient requests do not time out")
+	}
+
+	var agent string
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent = r.UserAgent()
+		io.WriteString(w, strings.Replace(testDaily, "%s", testDate.Format(PublishedFormat), 1))
+	}))
+	t.Cleanup(srv.Close)
+
+	var client = *DefaultClient
+	client.URLTemplate = srv.URL + "/scripts/XML_daily.asp?date_req=%s"
+	_, err := client.Fetch(context.Background(), "usd", testDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agent != DefaultUserAgent {
+		t.Errorf("User-Agent = %q, want %q", agent, DefaultUserAgent)
+	}
+}
+
+func TestFetchNotPublished(t *testing.T) {
+	client, requests := newTestServer(t)
+
+	_, err := client.Fetch(context.Background(), "usd", testDate)
+	var notPublished *NotPublishedError
+	if !errors.As(err, &notPublished) || !notPublished.Date.Equal(testDate) {
+		t.Fatalf("error = %v, want a NotPublishedError for %v", err, testDate)
+	}
+	if n := requests.Load(); n != MaxFallbackDays+1 {
+		t.Errorf("requests = %d, want %d", n, MaxFallbackDays+1)
+	}
+}
+
+func TestFetchNotFound(t *testing.T) {
+	client, _ := newTestServer(t, testDate)
+
+	_, err := client.Fetch(context.Background(), "xyz", testDate)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFetchGzip(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		var zw = gzip.NewWriter(&buf)
+		io.WriteString(zw, strings.Replace(testDaily, "%s", "14.10.2026", 1))
+		zw.Close()
+
+		w.Header().Set("Content-Type", "application/xml; charset=windows-1251")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	var client = &Client{HTTPClient: srv.Client(), URLTemplate: srv.URL + "/?date_req=%s"}
+	rate, err := client.Fetch(context.Background(), "usd", testDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate.Value != 92.5012 {
+		t.Errorf("value = %v, want 92.5012", rate.Value)
+	}
+}
+
+func TestGetContentType(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "<html>maintenance</html>")
+	}))
+	defer srv.Close()
+
+	var client = &Client{HTTPClient: srv.Client(), Retries: 3}
+	_, _, err := client.Get(context.Background(), srv.URL, Validators{})
+
+	var ce *ContentTypeError
+	var netErr *NetworkError
+	if !errors.As(err, &ce) || !errors.As(err, &netErr) || netErr.Attempts != 1 {
+		t.Fatalf("error = %v, want a ContentTypeError of 1 attempt", err)
+	}
+	if ce.Snippet != "<html>maintenance</html>" {
+		t.Errorf("snippet = %q", ce.Snippet)
+	}
+}
+
+func TestGetRetries(t *testing.T) {
+	client, url, requests := failingServer(t, http.StatusServiceUnavailable, 2)
+	client.Retries = 3
+
+	var retried []int
+	client.OnRetry = func(url string, attempt int, err error, delay time.Duration) {
+		retried = append(retried, attempt)
+	}
+
+	res, _, err := client.Get(context.Background(), url, Validators{})
+	if err != nil {
+		t.Fatalf("get after 2 failures: %v", err)
+	}
+	res.Body.Close()
+
+	if n := requests.Load(); n != 3 {
+		t.Errorf("requests = %d, want 3", n)
+	}
+	if len(retried) != 2 {
+		t.Errorf("retried attempts = %v, want [1 2]", retried)
+	}
+}
+
+func TestGetRetriesExhausted(t *testing.T) {
+	client, url, requests := failingServer(t, http.StatusInternalServerError, 10)
+	client.Retries = 2
+
+	_, _, err := client.Get(context.Background(), url, Validators{})
+
+	var netErr *NetworkError
+	var se *StatusError
+	if !errors.As(err, &netErr) || netErr.Attempts != 3 || !errors.As(err, &se) || se.Code != http.StatusInternalServerError {
+		t.Fatalf("error = %v, want a NetworkError of 3 attempts wrapping the 500", err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("requests = %d, want 3", n)
+	}
+}
+
+func TestGetNoRetryOn4xx(t *testing.T) {
+	client, url, requests := failingServer(t, http.StatusNotFound, 10)
+	client.Retries = 3
+
+	_, _, err := client.Get(context.Background(), url, Validators{})
+
+	var netErr *NetworkError
+	if !errors.As(err, &netErr) || netErr.Attempts != 1 {
+		t.Fatalf("error = %v, want a NetworkError of 1 attempt", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
+
+func TestRateJSON(t *testing.T) {
+	var rate = Rate{Date: testDate, Code: "JPY", Name: "Yen", Value: 62.3456, Nominal: 100}
+
+	data, err := rate.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"date":"2026-10-14","code":"JPY","name":"Yen","value":62.3456,"nominal":100,"rate":0.623456}`; string(data) != want {
+		t.Errorf("json = %s, want %s", data, want)
+	}
+
+	var got Rate
+	err = got.UnmarshalJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != rate {
+		t.Errorf("decoded = %+v, want %+v", got, rate)
+	}
+
+	// the methods are used by encoding/json, the name is omitted when empty
+	var rates = []Rate{rate, {Date: testDate, Code: "USD", Value: 92.5012, Nominal: 1}}
+	data, err = json.Marshal(rates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"name":""`) {
+		t.Errorf("json = %s, want no empty name", data)
+	}
+	var decoded []Rate
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, rates) {
+		t.Errorf("round trip = %+v, want %+v", decoded, rates)
+	}
+
+	err = json.Unmarshal([]byte(`{"date":"14.10.2026","code":"USD","value":1,"nominal":1}`), &got)
+	if err == nil || !strings.Contains(err.Error(), "invalid rate date '14.10.2026'") {
+		t.Errorf("decode of a non ISO 8601 date error = %v", err)
+	}
+}
+
+func TestRateString(t *testing.T) {
+	var tests = []struct {
+		rate Rate
+		want string
+	}{
+		{rate: Rate{Date: testDate, Code: "USD", Value: 90.5, Nominal: 1}, want: "14.10.2026\tUSD\t90.50"},
+		{rate: Rate{Date: testDate, Code: "USD", Value: 90, Nominal: 1}, want: "14.10.2026\tUSD\t90.00"},
+		// the price of a unit
+		{rate: Rate{Date: testDate, Code: "JPY", Value: 62.3456, Nominal: 100}, want: "14.10.2026\tJPY\t0.62"},
+		{rate: Rate{Date: testDate, Code: "EUR", Value: 100.1256, Nominal: 1}, want: "14.10.2026\tEUR\t100.13"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rate.String(); got != tt.want {
+			t.Errorf("%s String() = %q, want %q", tt.rate.Code, got, tt.want)
+		}
+		// a fmt.Stringer
+		if got := fmt.Sprint(tt.rate); got != tt.want {
+			t.Errorf("%s fmt.Sprint() = %q, want %q", tt.rate.Code, got, tt.want)
+		}
+	}
+}
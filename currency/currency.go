@@ -0,0 +1,261 @@
+// Package currency fetches the daily exchange rates of the Central Bank of
+// Russia.
+package currency
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+const (
+	// URLTemplate is the address of the daily rates, formatted with the
+	// date in DateFormat.
+	URLTemplate = "https://www.cbr.ru/scripts/XML_daily.asp?date_req=%s"
+	// DynamicURLTemplate is the address of the rate history of a currency,
+	// formatted with the first and the last dates in DateFormat and the
+	// Valute ID.
+	DynamicURLTemplate = "https://www.cbr.ru/scripts/XML_dynamic.asp?date_req1=%s&date_req2=%s&VAL_NM_RQ=%s"
+	// DateFormat is the date format of the rates request.
+	DateFormat = "02/01/2006"
+	// PublishedFormat is the date format of the ValCurs Date attribute.
+	PublishedFormat = "02.01.2006"
+)
+
+var (
+	// ErrEmptyResponse is returned for a response without a body.
+	ErrEmptyResponse = errors.New("empty response from CBR")
+	// ErrNotFound is returned when the currency has no published rate.
+	ErrNotFound = errors.New("currency not found")
+	// ErrNotModified is returned for a 304 response to a conditional
+	// request.
+	ErrNotModified = errors.New("not modified")
+	// ErrTooManyRedirects is returned by an http.Client CheckRedirect
+	// function which stops following redirects, the request is not retried.
+	ErrTooManyRedirects = errors.New("too many redirects")
+)
+
+// Rate is the rate of a currency in rubles.
+type Rate struct {
+	// Date the rate was published for.
+	Date time.Time
+	// Code is the upper-case ISO 4217 letter code.
+	Code string
+	Name string
+	// Value is the price of Nominal units of the currency.
+	Value   float64
+	Nominal int64
+}
+
+// PerUnit returns the price of a single unit of the currency.
+func (r Rate) PerUnit() float64 {
+	return r.Value / float64(r.Nominal)
+}
+
+// String returns the rate as a TSV line of the published date, the code
+// and the price of a unit with two decimals: "02.01.2006\tUSD\t90.50".
+func (r Rate) String() string {
+	return r.Date.Format(PublishedFormat) + "\t" + r.Code + "\t" + strconv.FormatFloat(r.PerUnit(), 'f', 2, 64)
+}
+
+// jsonDateFormat is the ISO 8601 date of the JSON encoding.
+const jsonDateFormat = "2006-01-02"
+
+type jsonRate struct {
+	Date    string  `json:"date"`
+	Code    string  `json:"code"`
+	Name    string  `json:"name,omitempty"`
+	Value   float64 `json:"value"`
+	Nominal int64   `json:"nominal"`
+	Rate    float64 `json:"rate"`
+}
+
+// MarshalJSON encodes the rate as an object with the ISO 8601 date, the
+// code, the name, the published value and nominal, and the rate per unit.
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonRate{
+		Date:    r.Date.Format(jsonDateFormat),
+		Code:    r.Code,
+		Name:    r.Name,
+		Value:   r.Value,
+		Nominal: r.Nominal,
+		Rate:    r.PerUnit(),
+	})
+}
+
+// UnmarshalJSON decodes a rate encoded by MarshalJSON, the rate per unit is
+// derived from the value and the nominal.
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	var j jsonRate
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return err
+	}
+
+	date, err := time.Parse(jsonDateFormat, j.Date)
+	if err != nil {
+		return fmt.Errorf("invalid rate date '%s': %w", j.Date, err)
+	}
+
+	*r = Rate{Date: date, Code: j.Code, Name: j.Name, Value: j.Value, Nominal: j.Nominal}
+	return nil
+}
+
+// Valute is a currency of the daily rates document.
+type Valute struct {
+	XMLName  xml.Name `xml:"Valute"`
+	ID       string   `xml:"ID,attr,omitempty"`
+	NumCode  int64    `xml:"NumCode"`
+	CharCode string   `xml:"CharCode"`
+	Nominal  int64    `xml:"Nominal"`
+	Name     string   `xml:"Name,omitempty"`
+	Value    string   `xml:"Value"`
+}
+
+// ValCurs is the daily rates document.
+type ValCurs struct {
+	XMLName xml.Name  `xml:"ValCurs"`
+	Date    string    `xml:"Date,attr"`
+	Name    string    `xml:"name,attr"`
+	Valutes []*Valute `xml:"Valute"`
+}
+
+// ParseValue parses a rate published with a comma or a dot as the decimal
+// separator, surrounding whitespace is ignored. Only digits and an optional
+// sign are accepted around the separator, NaN, Inf and exponents are not.
+func ParseValue(s string) (float64, error) {
+	valStr := strings.TrimSpace(s)
+	if valStr == "" {
+		return 0, errors.New("empty value")
+	}
+
+	if strings.Count(valStr, ",")+strings.Count(valStr, ".") > 1 {
+		return 0, fmt.Errorf("more than one decimal separator in '%s'", valStr)
+	}
+
+	for i, c := range valStr {
+		if (c < '0' || c > '9') && c != ',' && c != '.' && (i > 0 || (c != '-' && c != '+')) {
+			return 0, fmt.Errorf("'%s' is not a decimal number", valStr)
+		}
+	}
+
+	valStr = strings.Replace(valStr, ",", ".", -1)
+	return strconv.ParseFloat(valStr, 64)
+}
+
+// Record is a rate of the history document.
+type Record struct {
+	XMLName xml.Name `xml:"Record"`
+	Date    string   `xml:"Date,attr"`
+	ID      string   `xml:"Id,attr"`
+	Nominal int64    `xml:"Nominal"`
+	Value   string   `xml:"Value"`
+}
+
+// Dynamic is the rate history document of a currency.
+type Dynamic struct {
+	XMLName xml.Name `xml:"ValCurs"`
+	ID      string   `xml:"ID,attr,omitempty"`
+	From    string   `xml:"DateRange1,attr"`
+	To      string   `xml:"DateRange2,attr"`
+	Records []Record `xml:"Record"`
+}
+
+// Decode decodes the windows-1251 daily rates document.
+func Decode(body []byte) (v ValCurs, err error) {
+	err = decode(body, &v)
+	return
+}
+
+// DecodeDynamic decodes the windows-1251 rate history document.
+func DecodeDynamic(body []byte) (v Dynamic, err error) {
+	err = decode(body, &v)
+	return
+}
+
+// windows1251Labels are the charset labels of windows-1251 seen from CBR
+// and intermediaries, lower-case.
+var windows1251Labels = map[string]bool{
+	"windows-1251": true,
+	"cp1251":       true,
+	"win-1251":     true,
+	"x-cp1251":     true,
+}
+
+// utf16Labels are read as is, the body is transcoded to UTF-8 by its byte
+// order mark before decoding.
+var utf16Labels = map[string]bool{
+	"utf-16":   true,
+	"utf-16le": true,
+	"utf-16be": true,
+}
+
+func decode(body []byte, v interface{}) error {
+	// a UTF-8 byte order mark is dropped and UTF-16 with one becomes UTF-8
+	body, _, err := transform.Bytes(unicode.BOMOverride(transform.Nop), body)
+	if err != nil {
+		return fmt.Errorf("invalid byte order mark: %w", err)
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ErrEmptyResponse
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(body))
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		var label = strings.ToLower(strings.TrimSpace(charset))
+		switch {
+		case windows1251Labels[label]:
+			return charmap.Windows1251.NewDecoder().Reader(input), nil
+		case utf16Labels[label]:
+			return input, nil
+		default:
+			return nil, fmt.Errorf("unknown charset: %s", charset)
+		}
+	}
+	return d.Decode(v)
+}
+
+// PublishedDate parses the publication date of v in the location loc.
+func (v ValCurs) PublishedDate(loc *time.Location) (date time.Time, err error) {
+	date, err = time.ParseInLocation(PublishedFormat, v.Date, loc)
+	if err != nil {
+		err = fmt.Errorf("invalid publication date '%s'", v.Date)
+	}
+	return
+}
+
+// Rates returns the typed rates of v.
+func (v ValCurs) Rates(loc *time.Location) (rates []Rate, err error) {
+	date, err := v.PublishedDate(loc)
+	if err != nil {
+		return
+	}
+
+	for _, val := range v.Valutes {
+		value, err := ParseValue(val.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value '%s' for %s: %w", val.Value, val.CharCode, err)
+		}
+
+		rates = append(rates, Rate{
+			Date:    date,
+			Code:    strings.ToUpper(val.CharCode),
+			Name:    val.Name,
+			Value:   value,
+			Nominal: val.Nominal,
+		})
+	}
+
+	return
+}
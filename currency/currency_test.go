@@ -0,0 +1,98 @@
+package currency
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestParseValue(t *testing.T) {
+	var tests = []struct {
+		s    string
+		want float64
+		err  string
+	}{
+		{s: "92,5012", want: 92.5012},
+		{s: "92.5012", want: 92.5012},
+		{s: " 92,50\t\n", want: 92.5},
+		{s: "100", want: 100},
+		{s: "-1,5", want: -1.5},
+		{s: "", err: "empty value"},
+		{s: " \t", err: "empty value"},
+		{s: "1,234,56", err: "more than one decimal separator in '1,234,56'"},
+		{s: "1.234,56", err: "more than one decimal separator in '1.234,56'"},
+		{s: "1 234,56", err: "'1 234,56' is not a decimal number"},
+		{s: "NaN", err: "'NaN' is not a decimal number"},
+		{s: "Inf", err: "'Inf' is not a decimal number"},
+		{s: "-Inf", err: "'-Inf' is not a decimal number"},
+		{s: "1e3", err: "'1e3' is not a decimal number"},
+		{s: "0x1p-2", err: "'0x1p-2' is not a decimal number"},
+		{s: ",", err: "invalid syntax"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseValue(tt.s)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseValue(%q) = %v, %v, want error %q", tt.s, got, err, tt.err)
+			}
+			continue
+		}
+
+		if err != nil || got != tt.want {
+			t.Errorf("ParseValue(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+		}
+	}
+}
+
+func TestDecodeCharsets(t *testing.T) {
+	var document = func(label string) string {
+		return `<?xml version="1.0" encoding="` + label + `"?><ValCurs Date="14.10.2026" name="Foreign Currency Market">` +
+			`<Valute ID="R01235"><NumCode>840</NumCode><CharCode>USD</CharCode><Nominal>1</Nominal><Name>Доллар США</Name><Value>92,5012</Value></Valute></ValCurs>`
+	}
+	var encode = func(e *encoding.Encoder, s string) []byte {
+		b, err := e.Bytes([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	var utf8BOM = []byte("\xef\xbb\xbf")
+
+	var tests = []struct {
+		name string
+		body []byte
+	}{
+		{name: "windows-1251", body: encode(charmap.Windows1251.NewEncoder(), document("windows-1251"))},
+		{name: "upper-case label", body: encode(charmap.Windows1251.NewEncoder(), document("WINDOWS-1251"))},
+		{name: "cp1251", body: encode(charmap.Windows1251.NewEncoder(), document("cp1251"))},
+		{name: "win-1251", body: encode(charmap.Windows1251.NewEncoder(), document("Win-1251"))},
+		{name: "x-cp1251", body: encode(charmap.Windows1251.NewEncoder(), document("x-cp1251"))},
+		{name: "utf-8 bom", body: append(utf8BOM, document("utf-8")...)},
+		{name: "windows-1251 after a utf-8 bom", body: append(utf8BOM, encode(charmap.Windows1251.NewEncoder(), document("windows-1251"))...)},
+		{name: "utf-16le bom", body: encode(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder(), document("utf-16"))},
+		{name: "utf-16be bom", body: encode(unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewEncoder(), document("UTF-16"))},
+	}
+
+	for _, tt := range tests {
+		v, err := Decode(tt.body)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(v.Valutes) != 1 || v.Valutes[0].Name != "Доллар США" || v.Valutes[0].Value != "92,5012" {
+			t.Errorf("%s: valutes = %+v, want the decoded USD", tt.name, v.Valutes)
+		}
+	}
+
+	if _, err := Decode(encode(charmap.KOI8R.NewEncoder(), document("koi8-r"))); err == nil || !strings.Contains(err.Error(), "unknown charset: koi8-r") {
+		t.Errorf("koi8-r error = %v, want an unknown charset", err)
+	}
+	if _, err := Decode(utf8BOM); !errors.Is(err, ErrEmptyResponse) {
+		t.Errorf("byte order mark only error = %v, want ErrEmptyResponse", err)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// resolveDate returns the requested date: an explicit date wins over the
+// days before today.
+func resolveDate(date string, daysBefore int, allowFuture bool) (t time.Time, err error) {
+	if date == "" {
+		return now().Add(time.Duration(-daysBefore) * 24 * time.Hour), nil
+	}
+
+	t, err = parseDate(date)
+	if err != nil {
+		return
+	}
+
+	if !allowFuture && truncateDay(t).After(truncateDay(now())) {
+		err = fmt.Errorf("date %s is in the future, CBR has no rates for it", date)
+	}
+
+	return
+}
+
+func parseDate(date string) (t time.Time, err error) {
+	t, err = time.ParseInLocation(outputDateFormat, date, time.Local)
+	if err != nil {
+		err = fmt.Errorf("invalid date '%s', expected format %s", date, outputDateFormat)
+	}
+	return
+}
+
+// resolveDateRange returns every day from the first to the last date
+// inclusive.
+func resolveDateRange(from, to string, allowFuture bool) (dates []time.Time, err error) {
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("both --from-date and --to-date are required for a date range")
+	}
+
+	first, err := resolveDate(from, 0, allowFuture)
+	if err != nil {
+		return
+	}
+
+	last, err := resolveDate(to, 0, allowFuture)
+	if err != nil {
+		return
+	}
+
+	if last.Before(first) {
+		return nil, fmt.Errorf("--to-date %s is before --from-date %s", to, from)
+	}
+
+	for t := first; !t.After(last); t = t.AddDate(0, 0, 1) {
+		dates = append(dates, t)
+	}
+
+	return
+}
+
+var sinceUnits = map[string]bool{"d": true, "w": true, "mo": true, "y": true}
+
+// resolveSince returns the date a relative duration such as 7d, 2w, 1mo
+// or 1y before today. Months and years are calendar ones, clamped to the
+// end of a shorter month: 1mo before 31.03 is 29.02 or 28.02.
+func resolveSince(since string) (t time.Time, err error) {
+	var i = strings.IndexFunc(since, func(r rune) bool { return r < '0' || r > '9' })
+	if i <= 0 || !sinceUnits[since[i:]] {
+		return t, fmt.Errorf("invalid duration '%s', expected a number of d, w, mo or y", since)
+	}
+
+	n, err := strconv.Atoi(since[:i])
+	if err != nil {
+		return t, fmt.Errorf("invalid duration '%s': %w", since, err)
+	}
+
+	t = now()
+	switch since[i:] {
+	case "d":
+		return t.AddDate(0, 0, -n), nil
+	case "w":
+		return t.AddDate(0, 0, -7*n), nil
+	case "mo":
+		return addMonths(t, -n), nil
+	default:
+		return addMonths(t, -12*n), nil
+	}
+}
+
+// addMonths adds n calendar months to t, keeping the day of the month
+// unless the target month is shorter.
+func addMonths(t time.Time, n int) time.Time {
+	var first = time.Date(t.Year(), t.Month()+time.Month(n), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	var last = first.AddDate(0, 1, -1).Day()
+
+	var day = t.Day()
+	if day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// dateFormatAliases name common layouts of --date-format.
+var dateFormatAliases = map[string]string{
+	"iso": "2006-01-02",
+	"eu":  outputDateFormat,
+	"us":  "01/02/2006",
+}
+
+// resolveDateFormat returns the Go layout of --date-format, an alias or a
+// layout which must keep the day, the month and the year of a date.
+func resolveDateFormat(s string) (layout string, err error) {
+	if alias, ok := dateFormatAliases[strings.ToLower(s)]; ok {
+		return alias, nil
+	}
+
+	var probe = time.Date(2006, time.November, 25, 0, 0, 0, 0, time.UTC)
+	parsed, err := time.Parse(s, probe.Format(s))
+	if err != nil || !parsed.Equal(probe) {
+		return "", fmt.Errorf("invalid date format '%s', expected iso, eu, us or a Go layout like 2006-01-02", s)
+	}
+	return s, nil
+}
+
+// formatDates returns copies of the rows with the date columns in the
+// layout, the rows are built with outputDateFormat dates.
+func formatDates(header []string, rows [][]string, layout string) [][]string {
+	if layout == "" || layout == outputDateFormat {
+		return rows
+	}
+
+	var formatted = make([][]string, len(rows))
+	for i, row := range rows {
+		formatted[i] = append([]string(nil), row...)
+		for j, name := range header {
+			if !dateColumns[name] || j >= len(row) {
+				continue
+			}
+
+			t, err := time.Parse(outputDateFormat, row[j])
+			if err == nil {
+				formatted[i][j] = t.Format(layout)
+			}
+		}
+	}
+	return formatted
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveDate(t *testing.T) {
+	var today = testDate.Add(12 * time.Hour)
+	set(t, &now, func() time.Time { return today })
+
+	var tests = []struct {
+		date        string
+		daysBefore  int
+		allowFuture bool
+		want        time.Time
+		err         string
+	}{
+		{want: today},
+		{daysBefore: 3, want: today.AddDate(0, 0, -3)},
+		{date: "13.10.2026", want: testDate.AddDate(0, 0, -1)},
+		{date: "13.10.2026", daysBefore: 5, want: testDate.AddDate(0, 0, -1)},
+		{date: "14.10.2026", want: testDate},
+		{date: "2026-10-13", err: "invalid date '2026-10-13'"},
+		{date: "32.10.2026", err: "invalid date '32.10.2026'"},
+		{date: "15.10.2026", err: "is in the future"},
+		{date: "15.10.2026", allowFuture: true, want: testDate.AddDate(0, 0, 1)},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveDate(tt.date, tt.daysBefore, tt.allowFuture)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("resolveDate(%q, %d) error = %v, want %q", tt.date, tt.daysBefore, err, tt.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("resolveDate(%q, %d): %v", tt.date, tt.daysBefore, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("resolveDate(%q, %d) = %v, want %v", tt.date, tt.daysBefore, got, tt.want)
+		}
+	}
+}
+
+func TestResolveSince(t *testing.T) {
+	var date = func(day int, month time.Month, year int) time.Time {
+		return time.Date(year, month, day, 12, 0, 0, 0, time.Local)
+	}
+
+	var tests = []struct {
+		today time.Time
+		since string
+		want  time.Time
+		err   bool
+	}{
+		{today: date(14, 10, 2026), since: "7d", want: date(7, 10, 2026)},
+		{today: date(14, 10, 2026), since: "0d", want: date(14, 10, 2026)},
+		{today: date(14, 10, 2026), since: "2w", want: date(30, 9, 2026)},
+		{today: date(14, 10, 2026), since: "1mo", want: date(14, 9, 2026)},
+		{today: date(14, 10, 2026), since: "1y", want: date(14, 10, 2025)},
+		// the end of a shorter month
+		{today: date(31, 3, 2026), since: "1mo", want: date(28, 2, 2026)},
+		{today: date(31, 3, 2028), since: "1mo", want: date(29, 2, 2028)},
+		{today: date(31, 5, 2026), since: "1mo", want: date(30, 4, 2026)},
+		{today: date(31, 1, 2026), since: "2mo", want: date(30, 11, 2025)},
+		{today: date(29, 2, 2028), since: "1y", want: date(28, 2, 2027)},
+		{today: date(14, 10, 2026), since: "7", err: true},
+		{today: date(14, 10, 2026), since: "d", err: true},
+		{today: date(14, 10, 2026), since: "-7d", err: true},
+		{today: date(14, 10, 2026), since: "1m", err: true},
+		{today: date(14, 10, 2026), since: "99999999999999999999d", err: true},
+	}
+
+	for _, tt := range tests {
+		var today = tt.today
+		set(t, &now, func() time.Time { return today })
+
+		got, err := resolveSince(tt.since)
+		if tt.err {
+			if err == nil {
+				t.Errorf("resolveSince(%q) = %v, want an error", tt.since, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("resolveSince(%q): %v", tt.since, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("resolveSince(%q) on %s = %s, want %s", tt.since, ratesKey(tt.today), ratesKey(got), ratesKey(tt.want))
+		}
+	}
+}
+
+func TestResolveDateFormat(t *testing.T) {
+	var tests = []struct {
+		format string
+		want   string
+	}{
+		{format: "iso", want: "2026-10-14"},
+		{format: "ISO", want: "2026-10-14"},
+		{format: "eu", want: "14.10.2026"},
+		{format: "us", want: "10/14/2026"},
+		{format: "02 Jan 2006", want: "14 Oct 2026"},
+		{format: "20060102", want: "20261014"},
+	}
+
+	for _, tt := range tests {
+		layout, err := resolveDateFormat(tt.format)
+		if err != nil {
+			t.Errorf("resolveDateFormat(%q): %v", tt.format, err)
+			continue
+		}
+		if got := testDate.Format(layout); got != tt.want {
+			t.Errorf("resolveDateFormat(%q) formats %s, want %s", tt.format, got, tt.want)
+		}
+	}
+
+	// layouts which lose the day, the month or the year, or are no layout
+	for _, format := range []string{"yyyy-mm-dd", "2006-01", "01-02", "Jan 2", ""} {
+		if layout, err := resolveDateFormat(format); err == nil {
+			t.Errorf("resolveDateFormat(%q) = %q, want an error", format, layout)
+		}
+	}
+}
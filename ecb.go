@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"currency/currency"
+)
+
+const (
+	ecbRecentURL  = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist-90d.xml"
+	ecbHistoryURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.xml"
+	ecbDateFormat = "2006-01-02"
+	ecbRecentDays = 90
+)
+
+type ecbEnvelope struct {
+	XMLName xml.Name `xml:"Envelope"`
+	Days    []ecbDay `xml:"Cube>Cube"`
+}
+
+type ecbDay struct {
+	Time  string    `xml:"time,attr"`
+	Rates []ecbRate `xml:"Cube"`
+}
+
+type ecbRate struct {
+	Currency string `xml:"currency,attr"`
+	Rate     string `xml:"rate,attr"`
+}
+
+// ecbProvider fetches the euro reference rates of the European Central
+// Bank. ECB quotes units of a currency per euro, the rates are inverted to
+// match the CBR notion of a base currency value per unit.
+type ecbProvider struct{}
+
+func (ecbProvider) Name() string {
+	return ecbProviderName
+}
+
+func (ecbProvider) Base() string {
+	return eurCurrency
+}
+
+// URL picks the recent rates unless t is older than they cover.
+func (ecbProvider) URL(client *currency.Client, t time.Time) string {
+	if now().Sub(t) > ecbRecentDays*24*time.Hour {
+		return ecbHistoryURL
+	}
+	return ecbRecentURL
+}
+
+func (p ecbProvider) Rates(ctx context.Context, client *currency.Client, t time.Time, fallback bool, cond currency.Validators) (valutes []*Valute, date time.Time, v currency.Validators, err error) {
+	res, v, err := client.Get(ctx, p.URL(client, t), cond)
+	if err != nil {
+		return
+	}
+
+	defer res.Body.Close()
+
+	var e ecbEnvelope
+	err = xml.NewDecoder(res.Body).Decode(&e)
+	if err != nil {
+		return
+	}
+
+	day, date, err := e.day(t, fallback)
+	if err != nil {
+		return
+	}
+
+	if !date.Equal(truncateDay(t)) {
+		logger.Debugf("no rates published for %s, falling back to %s", t.Format(outputDateFormat), date.Format(outputDateFormat))
+	}
+
+	valutes, err = day.valutes()
+	return valutes, date, v, err
+}
+
+// day picks the rates published for t, or with the fallback for the nearest
+// day before it.
+func (e ecbEnvelope) day(t time.Time, fallback bool) (day ecbDay, date time.Time, err error) {
+	var oldest = truncateDay(t).AddDate(0, 0, -maxFallbackDays)
+	if !fallback {
+		oldest = truncateDay(t)
+	}
+
+	var found bool
+	for _, d := range e.Days {
+		dt, err := time.ParseInLocation(ecbDateFormat, d.Time, t.Location())
+		if err != nil {
+			return day, date, fmt.Errorf("invalid ECB date '%s'", d.Time)
+		}
+
+		if dt.After(t) || dt.Before(oldest) {
+			continue
+		}
+
+		if !found || dt.After(date) {
+			day, date, found = d, dt, true
+		}
+	}
+
+	if !found && fallback {
+		err = &currency.NotPublishedError{Date: t, Days: maxFallbackDays}
+	}
+
+	if !found {
+		date = t
+	}
+
+	return
+}
+
+func (d ecbDay) valutes() (valutes []*Valute, err error) {
+	for _, r := range d.Rates {
+		rate, err := strconv.ParseFloat(r.Rate, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ECB rate '%s' for %s", r.Rate, r.Currency)
+		}
+
+		if rate == 0 {
+			return nil, fmt.Errorf("zero ECB rate for %s", r.Currency)
+		}
+
+		valutes = append(valutes, &Valute{
+			CharCode: strings.ToUpper(r.Currency),
+			Nominal:  1,
+			Value:    strconv.FormatFloat(1/rate, 'f', -1, 64),
+		})
+	}
+
+	return
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"currency/currency"
+)
+
+func TestECBProviderRates(t *testing.T) {
+	var client = newTestClient(&http.Client{Transport: fileTransport("testdata/ecb.xml")}, urlTemplate)
+	var published = testDate.AddDate(0, 0, -1)
+
+	var tests = []struct {
+		name     string
+		t        time.Time
+		fallback bool
+		date     time.Time
+		valutes  []Valute
+	}{
+		{
+			name:     "published",
+			t:        published,
+			fallback: true,
+			date:     published,
+			valutes: []Valute{
+				{CharCode: "USD", Nominal: 1, Value: "0.9156670634557275"},
+				{CharCode: "JPY", Nominal: 1, Value: "0.006324310650139135"},
+			},
+		},
+		{
+			name:     "fallback",
+			t:        testDate,
+			fallback: true,
+			date:     published,
+			valutes: []Valute{
+				{CharCode: "USD", Nominal: 1, Value: "0.9156670634557275"},
+				{CharCode: "JPY", Nominal: 1, Value: "0.006324310650139135"},
+			},
+		},
+		{
+			name: "no fallback",
+			t:    testDate,
+			date: testDate,
+		},
+	}
+
+	for _, tt := range tests {
+		valutes, date, _, err := ecbProvider{}.Rates(context.Background(), client, tt.t, tt.fallback, currency.Validators{})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !date.Equal(tt.date) {
+			t.Errorf("%s: date = %v, want %v", tt.name, date, tt.date)
+		}
+
+		var got []Valute
+		for _, v := range valutes {
+			got = append(got, *v)
+		}
+		if !reflect.DeepEqual(got, tt.valutes) {
+			t.Errorf("%s: valutes = %+v, want %+v", tt.name, got, tt.valutes)
+		}
+	}
+}
+
+func TestECBRowsQuotedInEuro(t *testing.T) {
+	var app = newTestApp(t)
+	app.opts.provider = ecbProvider{}
+	app.client = newTestClient(&http.Client{Transport: fileTransport("testdata/ecb.xml")}, urlTemplate)
+
+	row, err := app.getCurrencyItemCache(context.Background(), "usd", testDate.AddDate(0, 0, -1), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"13.10.2026", "USD", "0.92", "EUR", "1"}; !reflect.DeepEqual(row, want) {
+		t.Errorf("row = %v, want %v", row, want)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"currency/currency"
+)
+
+// CurrencyNotFoundError is returned when CBR publishes no rate for the
+// requested currency code on the date.
+type CurrencyNotFoundError struct {
+	Code string
+	Date time.Time
+}
+
+func (e *CurrencyNotFoundError) Error() string {
+	return fmt.Sprintf("cannot get currency rate for '%s' on %s", e.Code, e.Date.Format(outputDateFormat))
+}
+
+// DuplicateCodeError is returned in the strict mode when several
+// published valutes share a currency code.
+type DuplicateCodeError struct {
+	Code string
+	Date time.Time
+	IDs  []string
+}
+
+func (e *DuplicateCodeError) Error() string {
+	return fmt.Sprintf("currency code '%s' is published more than once on %s: %s",
+		e.Code, e.Date.Format(outputDateFormat), strings.Join(e.IDs, ", "))
+}
+
+// ImplausibleRateError is returned by --validate in the strict mode for a
+// rate per unit that is not positive or above the threshold.
+type ImplausibleRateError struct {
+	Code  string
+	Date  time.Time
+	Value float64
+	Max   float64
+}
+
+func (e *ImplausibleRateError) Error() string {
+	if e.Value <= 0 {
+		return fmt.Sprintf("implausible rate %v of %s on %s, expected a positive rate",
+			e.Value, e.Code, e.Date.Format(outputDateFormat))
+	}
+	return fmt.Sprintf("implausible rate %v of %s on %s, expected at most %v",
+		e.Value, e.Code, e.Date.Format(outputDateFormat), e.Max)
+}
+
+// NotCachedError is returned in the offline mode when the rates for the
+// date are not in the cache.
+type NotCachedError struct {
+	Date time.Time
+}
+
+func (e *NotCachedError) Error() string {
+	return fmt.Sprintf("rates for %s are not cached and the offline mode is on", e.Date.Format(outputDateFormat))
+}
+
+// UsageError reports invalid flags, arguments or configuration.
+type UsageError struct {
+	Err error
+}
+
+func (e *UsageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &UsageError{Err: fmt.Errorf(format, args...)}
+}
+
+// CacheError reports a failure to read or write the cache.
+type CacheError struct {
+	Err error
+}
+
+func (e *CacheError) Error() string {
+	return fmt.Sprintf("cache: %v", e.Err)
+}
+
+func (e *CacheError) Unwrap() error {
+	return e.Err
+}
+
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitUsage    = 2
+	exitNetwork  = 3
+	exitNotFound = 4
+	exitCache    = 5
+	exitInvalid  = 6
+)
+
+// exitCode maps an error to the exit code of its category.
+func exitCode(err error) int {
+	var (
+		usageErr     *UsageError
+		networkErr   *currency.NetworkError
+		notFoundErr  *CurrencyNotFoundError
+		notPublished *currency.NotPublishedError
+		cacheErr     *CacheError
+		notCached    *NotCachedError
+		implausible  *ImplausibleRateError
+	)
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usageErr):
+		return exitUsage
+	case errors.As(err, &networkErr):
+		return exitNetwork
+	case errors.As(err, &notFoundErr), errors.As(err, &notPublished):
+		return exitNotFound
+	case errors.As(err, &cacheErr), errors.As(err, &notCached):
+		return exitCache
+	case errors.As(err, &implausible):
+		return exitInvalid
+	default:
+		return exitError
+	}
+}
+
+// failure logs the error and returns its exit code.
+func failure(err error) int {
+	logger.Fail(err)
+	return exitCode(err)
+}
+
+// parseFailure returns the exit code for a flag parsing error, the flag
+// package has already reported it.
+func parseFailure(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	return exitUsage
+}
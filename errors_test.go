@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"currency/currency"
+)
+
+func TestCurrencyNotFoundError(t *testing.T) {
+	var app = newTestApp(t)
+	app.opts.provider = testProvider
+
+	_, err := app.getCurrencyItemCache(context.Background(), "xyz", testDate, false)
+	err = fmt.Errorf("line 3: %w", err)
+
+	var notFound *CurrencyNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("error %v is not a CurrencyNotFoundError", err)
+	}
+	if notFound.Code != "xyz" || !notFound.Date.Equal(testDate) {
+		t.Errorf("error for %s on %v, want xyz on %v", notFound.Code, notFound.Date, testDate)
+	}
+
+	var msg = err.Error()
+	if !strings.Contains(msg, "'xyz'") || !strings.Contains(msg, "14.10.2026") {
+		t.Errorf("message %q does not name the code and the date", msg)
+	}
+}
+
+func TestNetworkErrorIsNotCurrencyNotFound(t *testing.T) {
+	var err error = &currency.NetworkError{Attempts: 1, Err: &currency.StatusError{Code: 502, Status: "502 Bad Gateway"}}
+
+	var notFound *CurrencyNotFoundError
+	if errors.As(err, &notFound) {
+		t.Errorf("network error %v matches CurrencyNotFoundError", err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	fromProvider    = "provider"
+	fromCache       = "cache"
+	fromRevalidated = "cache, revalidated by the provider"
+	fromHistory     = "rate history"
+)
+
+// origin tells where the rates of a day were loaded from, for --explain.
+type origin struct {
+	from string
+	url  string
+	// at is when the rates were fetched from the provider
+	at time.Time
+}
+
+// explainRate writes how the rate of the currency for the requested date
+// was resolved, when --explain is set.
+func (a *App) explainRate(name string, requested time.Time, day *dayRates) {
+	if a.opts.explain == nil {
+		return
+	}
+
+	var url = day.origin.url
+	if url == "" {
+		url = "-"
+	}
+
+	var code = strings.ToLower(name)
+	if resolved := day.code(name); resolved != code {
+		code += " (" + resolved + ")"
+	}
+
+	var lines = []string{
+		fmt.Sprintf("explain %s for %s:", code, requested.Format(outputDateFormat)),
+		"  source:    " + day.origin.from,
+		"  published: " + day.date.Format(outputDateFormat),
+		"  url:       " + url,
+	}
+	if days := int(truncateDay(requested).Sub(truncateDay(day.date)).Hours() / 24); days > 0 {
+		lines[2] += fmt.Sprintf(", fallback of %d day(s)", days)
+	}
+
+	if val, ok := day.valutes[day.code(name)]; ok {
+		lines = append(lines, "  value:     "+val.Value+" for nominal "+strconv.FormatInt(val.Nominal, 10))
+	}
+	if row, ok := day.rows[day.code(name)]; ok {
+		lines = append(lines, "  rate:      "+row[2]+" "+row[3])
+	}
+
+	fmt.Fprintln(a.opts.explain, strings.Join(lines, "\n"))
+}
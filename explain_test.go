@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExplainFallback(t *testing.T) {
+	// the rates of the 14th are those published for the 12th
+	var published = testDate.AddDate(0, 0, -2)
+	var cbr = newFakeCBR(t, published)
+	var app = cbr.app(t)
+	var buf bytes.Buffer
+	app.opts.explain = &buf
+
+	var url = cbr.URL + "/scripts/XML_daily.asp?date_req=12/10/2026"
+	var want = "explain jpy for 14.10.2026:\n" +
+		"  source:    provider\n" +
+		"  published: 12.10.2026, fallback of 2 day(s)\n" +
+		"  url:       " + url + "\n" +
+		"  value:     62,3456 for nominal 100\n" +
+		"  rate:      0.62 RUB\n"
+	_, err := app.collectRows(context.Background(), []time.Time{testDate}, []string{"jpy"}, false, false, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("explain =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	// the second run reads the day from the cache
+	buf.Reset()
+	_, err = reopen(app).collectRows(context.Background(), []time.Time{testDate}, []string{"jpy"}, false, false, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "explain jpy for 14.10.2026:\n" +
+		"  source:    cache\n" +
+		"  published: 12.10.2026, fallback of 2 day(s)\n" +
+		"  url:       " + url + "\n" +
+		"  value:     62.3456 for nominal 100\n" +
+		"  rate:      0.62 RUB\n"
+	if buf.String() != want {
+		t.Errorf("cached explain =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
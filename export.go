@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+const createRatesTable = `CREATE TABLE IF NOT EXISTS rates (
+	date    TEXT    NOT NULL,
+	code    TEXT    NOT NULL,
+	value   REAL    NOT NULL,
+	nominal INTEGER NOT NULL,
+	UNIQUE (date, code)
+)`
+
+const upsertRate = `INSERT INTO rates (date, code, value, nominal) VALUES (?, ?, ?, ?)
+ON CONFLICT (date, code) DO UPDATE SET value = excluded.value, nominal = excluded.nominal`
+
+// exportSQLite upserts the published valutes of the rate rows into the
+// rates table of the SQLite database at path, creating both when missing.
+// The value, nominal and upper-case code are stored as published whatever
+// the options of the rows; rows without a rate are skipped.
+func (a *App) exportSQLite(ctx context.Context, path string, rows [][]string) (err error) {
+	var columns = map[string]int{}
+	for i, name := range a.opts.rows.header() {
+		columns[name] = i
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return fmt.Errorf("sqlite export: %w", err)
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, createRatesTable)
+	if err != nil {
+		return fmt.Errorf("sqlite export: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlite export: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, row := range rows {
+		if row[columns["rate"]] == "" {
+			continue
+		}
+
+		var code, date = row[columns["code"]], row[columns["date"]]
+		var v = a.published.valute(code, date)
+		if v == nil {
+			return fmt.Errorf("sqlite export: no published rate of %s for %s", code, date)
+		}
+
+		value, err := v.getRawValue()
+		if err != nil {
+			return fmt.Errorf("sqlite export: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, upsertRate, v.Date.Format(jsonDateFormat), strings.ToUpper(v.CharCode), value, v.Nominal)
+		if err != nil {
+			return fmt.Errorf("sqlite export: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("sqlite export: %w", err)
+	}
+	return nil
+}
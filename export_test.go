@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExportSQLite(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+	var app = cbr.app(t)
+	app.opts.rows.raw = true
+	app.opts.rows.codeCase = codeCaseLower
+	app.opts.base = eurCurrency
+
+	var ctx = context.Background()
+	rows, err := app.collectRows(ctx, []time.Time{testDate}, []string{"usd", "jpy"}, false, false, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second export updates the rows of the first one
+	var path = filepath.Join(t.TempDir(), "rates.db")
+	for i := 0; i < 2; i++ {
+		err = app.exportSQLite(ctx, path, rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	res, err := db.Query("SELECT date, code, value, nominal FROM rates ORDER BY code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Close()
+
+	type rate struct {
+		date, code string
+		value      float64
+		nominal    int64
+	}
+	var got []rate
+	for res.Next() {
+		var r rate
+		err = res.Scan(&r.date, &r.code, &r.value, &r.nominal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	if err := res.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the published values, whatever --raw, --code-case and --base
+	var want = []rate{
+		{date: "2026-10-14", code: "JPY", value: 62.3456, nominal: 100},
+		{date: "2026-10-14", code: "USD", value: 92.5012, nominal: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rates = %+v, want %+v", got, want)
+	}
+}
+
+func TestExportSQLiteEvicted(t *testing.T) {
+	// a range longer than the memory cache
+	var dates = []time.Time{testDate.AddDate(0, 0, -1), testDate}
+	var cbr = newFakeCBR(t, dates...)
+	var app = cbr.app(t)
+	app = newApp(app.db, app.client, newMemoryRates(1), app.opts)
+
+	var ctx = context.Background()
+	rows, err := app.collectRows(ctx, dates, []string{"usd"}, false, true, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var path = filepath.Join(t.TempDir(), "rates.db")
+	err = app.exportSQLite(ctx, path, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var n int
+	err = db.QueryRow("SELECT count(*) FROM rates").Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(dates) {
+		t.Errorf("exported rates = %d, want %d", n, len(dates))
+	}
+}
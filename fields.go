@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// fieldOptions are the columns --fields selects from, each with the
+// option that adds it to the rows; the columns without one are always
+// there.
+var fieldOptions = map[string]func(o *rowOptions){
+	"date":           nil,
+	"code":           nil,
+	"rate":           nil,
+	"base":           nil,
+	"nominal":        nil,
+	"name":           func(o *rowOptions) { o.withName = true },
+	"id":             func(o *rowOptions) { o.withID = true },
+	"inverse":        func(o *rowOptions) { o.withInverse = true },
+	"requested_date": func(o *rowOptions) { o.withRequestedDate = true },
+	"change":         func(o *rowOptions) { o.withChange = true },
+	"change_percent": func(o *rowOptions) { o.withChange = true },
+}
+
+// parseFields parses the comma-separated --fields and enables the optional
+// columns they name in o.
+func parseFields(s string, o *rowOptions) (fields []string, err error) {
+	var seen = map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		enable, ok := fieldOptions[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field '%s', expected one of: %s", name, strings.Join(fieldNames(), ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("field '%s' is selected more than once", name)
+		}
+
+		seen[name] = true
+		if enable != nil {
+			enable(o)
+		}
+		fields = append(fields, name)
+	}
+	return
+}
+
+func fieldNames() (names []string) {
+	for name := range fieldOptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// selectFields returns the header and the rows of the --fields columns in
+// their order, all the columns when no fields are selected.
+func (o rowOptions) selectFields(rows [][]string) (header []string, selected [][]string) {
+	header = o.header()
+	if len(o.fields) == 0 {
+		return header, rows
+	}
+
+	var columns = make([]int, len(o.fields))
+	for i, name := range o.fields {
+		columns[i] = columnIndex(header, name)
+	}
+
+	selected = make([][]string, len(rows))
+	for i, row := range rows {
+		selected[i] = make([]string, len(columns))
+		for j, c := range columns {
+			if c >= 0 && c < len(row) {
+				selected[i][j] = row[c]
+			}
+		}
+	}
+	return o.fields, selected
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	var o rowOptions
+	fields, err := parseFields(" Name, code ,inverse,rate", &o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"name", "code", "inverse", "rate"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %v, want %v", fields, want)
+	}
+	if !o.withName || !o.withInverse || o.withID || o.withChange {
+		t.Errorf("options = %+v, want only the name and inverse columns enabled", o)
+	}
+
+	var tests = []struct {
+		fields string
+		err    string
+	}{
+		{fields: "date,currency", err: "unknown field 'currency', expected one of: base, change, change_percent, code, date, id, inverse, name, nominal, rate, requested_date"},
+		{fields: "date,", err: "unknown field ''"},
+		{fields: "code,rate,CODE", err: "field 'code' is selected more than once"},
+	}
+	for _, tt := range tests {
+		_, err := parseFields(tt.fields, &rowOptions{})
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("parseFields(%q) error = %v, want %q", tt.fields, err, tt.err)
+		}
+	}
+}
+
+func TestFieldsOrder(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	var tests = []struct {
+		fields string
+		want   string
+	}{
+		{fields: "code,rate", want: "USD\t92.50\nJPY\t0.62\n"},
+		{fields: "rate,date,code", want: "92.50\t14.10.2026\tUSD\n0.62\t14.10.2026\tJPY\n"},
+		// the optional columns are enabled by their field
+		{fields: "id,name,nominal,code", want: "R01235\tДоллар США\t1\tUSD\nR01820\tЯпонских иен\t100\tJPY\n"},
+	}
+
+	for _, tt := range tests {
+		code, out := runCLI(t, "--url-template", cbr.template(), "--fields", tt.fields, "--currency", "usd,jpy", "--date", "14.10.2026")
+		if code != exitOK {
+			t.Fatalf("--fields %s: exit code = %d, want %d", tt.fields, code, exitOK)
+		}
+		if out != tt.want {
+			t.Errorf("--fields %s: output = %q, want %q", tt.fields, out, tt.want)
+		}
+	}
+
+	for _, fields := range []string{"code,currency", "rate,rate"} {
+		if code, _ := runCLI(t, "--url-template", cbr.template(), "--fields", fields, "--currency", "usd", "--date", "14.10.2026"); code != exitUsage {
+			t.Errorf("--fields %s: exit code = %d, want %d", fields, code, exitUsage)
+		}
+	}
+}
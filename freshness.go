@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"currency/currency"
+)
+
+// freshnessTolerance absorbs float noise when comparing rates.
+const freshnessTolerance = 1e-9
+
+// checkFreshness fetches the rates for t bypassing the cache and warns
+// about the currencies whose loaded rate differs from the fresh one, which
+// means the provider published an update since the rates were cached.
+// Without codes every currency of the day is checked.
+func (a *App) checkFreshness(ctx context.Context, t time.Time, codes []string) error {
+	day, ok := a.memDay(t)
+	if !ok {
+		return nil
+	}
+
+	if codes == nil {
+		codes = day.order
+	}
+
+	valutes, _, _, err := a.fetchRates(ctx, t, currency.Validators{})
+	if err != nil {
+		return err
+	}
+
+	cross, err := a.opts.crossValue(valutes, t)
+	if err != nil {
+		return err
+	}
+
+	var o = a.opts.rows
+	var fresh = map[string]float64{}
+	for _, val := range valutes {
+		value, err := val.getValue()
+		if err != nil {
+			return err
+		}
+		fresh[strings.ToLower(val.CharCode)] = value / cross
+	}
+
+	for _, code := range codes {
+		var c = day.code(code)
+		cur, ok := fresh[c]
+		if !ok {
+			continue
+		}
+
+		var cached = day.values[c]
+		if math.Abs(cur-cached) > freshnessTolerance {
+			logger.with("currency", c, "date", ratesKey(t)).Warnf("cached %s rate %s is stale, %s publishes %s, delta %s, use --skip-cache",
+				strings.ToUpper(c), o.format(cached), a.opts.provider.Name(), o.format(cur), o.format(cur-cached))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCheckFreshness(t *testing.T) {
+	var logs bytes.Buffer
+	set(t, &logger, newLogger(&logs, levelError))
+	clock(t, 10)
+
+	// today's USD rate was cached before the provider updated it
+	var app = newTestApp(t)
+	app.opts.provider = stubProvider{
+		{CharCode: "USD", Nominal: 1, Value: "90"},
+		{CharCode: "EUR", Nominal: 1, Value: "100,1234"},
+	}
+	var ctx = context.Background()
+	_, err := app.getCurrencyItemCache(ctx, "usd", testDate, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.opts.provider = testProvider
+	err = app.checkFreshness(ctx, testDate, []string{"usd", "eur"})
+	if err != nil {
+		t.Fatalf("checkFreshness() = %v, want a warning only", err)
+	}
+
+	var want = "cached USD rate 90.00 is stale, stub publishes 92.50, delta 2.50"
+	if !strings.Contains(logs.String(), want) {
+		t.Errorf("logs = %q, want %q", logs.String(), want)
+	}
+	if strings.Contains(logs.String(), "EUR") {
+		t.Errorf("logs = %q, want no warning for the unchanged EUR rate", logs.String())
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// readyTTL is how long a readiness result is reused, probes come
+	// every few seconds and must not hit the provider each time
+	readyTTL     = 10 * time.Second
+	readyTimeout = 5 * time.Second
+)
+
+// readiness caches the result of the last readiness check.
+type readiness struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+// checkReady reports whether a rate can be produced: today's rates load
+// from the memory, the cache or the provider, or a cached day for today
+// exists however old it is. The result is reused for readyTTL.
+func (a *App) checkReady(ctx context.Context) error {
+	var r = &a.ready
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.checkedAt.IsZero() && now().Sub(r.checkedAt) < readyTTL {
+		return r.err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	var t = now()
+	_, r.err = a.dayRatesCache(ctx, t, false)
+	if r.err != nil {
+		if _, ok, err := a.readCachedDay(t); err == nil && ok {
+			logger.Debugf("rates unavailable, ready with the cached rates: %v", r.err)
+			r.err = nil
+		}
+	}
+	r.checkedAt = now()
+	return r.err
+}
+
+// healthzHandler serves /healthz, the process is up when it answers.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyzHandler serves /readyz, 503 when no rate can be produced.
+func (a *App) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	err := a.checkReady(r.Context())
+	if err != nil {
+		writeJSONError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]string{"status": "ready"})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"currency/currency"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/time/rate"
+)
+
+// testDate is the date of the testdata rates, a Wednesday.
+var testDate = time.Date(2026, time.October, 14, 0, 0, 0, 0, time.Local)
+
+// set assigns v to the package variable *p until the test ends.
+func set[T any](t *testing.T, p *T, v T) {
+	t.Helper()
+	var old = *p
+	*p = v
+	t.Cleanup(func() { *p = old })
+}
+
+// newTestApp returns an App with a cache file in a temporary directory.
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "cache"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return newApp(db, newTestClient(&http.Client{Timeout: 5 * time.Second}, urlTemplate), newMemoryRates(defaultMemCacheSize), defaultOptions())
+}
+
+// newTestClient returns the client of the URL template made with
+// httpClient, it retries without delay.
+func newTestClient(httpClient *http.Client, urlTemplate string) *currency.Client {
+	var client = newClient(httpClient, urlTemplate, defaultUserAgent, defaultRetries, rate.NewLimiter(rate.Inf, 1), cbrProviderName)
+	client.RetryBackoff = time.Millisecond
+	return client
+}
+
+// emptyValCurs is the CBR answer for a date without published rates.
+const emptyValCurs = `<?xml version="1.0" encoding="windows-1251"?><ValCurs Date="01.01.2000" name="Foreign Currency Market"></ValCurs>`
+
+// fakeCBR serves testdata/daily.xml as the CBR daily rates of the
+// published dates, dated as requested, and an empty document for the other
+// dates.
+type fakeCBR struct {
+	*httptest.Server
+	published map[string]bool
+	requests  atomic.Int32
+}
+
+func newFakeCBR(t *testing.T, published ...time.Time) *fakeCBR {
+	t.Helper()
+	body, err := os.ReadFile("testdata/daily.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var f = &fakeCBR{published: map[string]bool{}}
+	for _, d := range published {
+		f.published[d.Format(xmlDateFormat)] = true
+	}
+
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.requests.Add(1)
+		w.Header().Set("Content-Type", "application/xml; charset=windows-1251")
+
+		var date = r.URL.Query().Get("date_req")
+		if !f.published[date] {
+			io.WriteString(w, emptyValCurs)
+			return
+		}
+		w.Write(bytes.Replace(body, []byte(`Date="14.10.2026"`), []byte(`Date="`+strings.Replace(date, "/", ".", -1)+`"`), 1))
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// template is the --url-template of the server.
+func (f *fakeCBR) template() string {
+	return f.URL + "/scripts/XML_daily.asp?date_req=%s"
+}
+
+// client returns a client of the server.
+func (f *fakeCBR) client() *currency.Client {
+	return newTestClient(f.Client(), f.template())
+}
+
+// app returns an App which requests the rates from the server.
+func (f *fakeCBR) app(t *testing.T) *App {
+	t.Helper()
+	var app = newTestApp(t)
+	app.client = f.client()
+	return app
+}
+
+// saveState restores the logger run sets up from the flags when the test
+// ends, the other settings live in the App of the run.
+func saveState(t *testing.T) {
+	set(t, &logger, logger)
+}
+
+// runCLI runs the command line and returns the exit code and what it wrote
+// to a temporary --output file. HOME and the cache path default to
+// temporary directories, so no config file or cache of the user is read.
+func runCLI(t *testing.T, args ...string) (code int, out string) {
+	t.Helper()
+	saveState(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(cachePathEnv, filepath.Join(t.TempDir(), "cache"))
+
+	var path = filepath.Join(t.TempDir(), "out")
+	code = run(append([]string{"--output", path}, args...))
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal(err)
+	}
+	return code, string(data)
+}
+
+// capture runs f with the standard file, os.Stdout or os.Stderr,
+// redirected and returns what was written to it.
+func capture(t *testing.T, file **os.File, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var out = make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+
+	var old = *file
+	*file = w
+	f()
+	*file = old
+	w.Close()
+	return <-out
+}
+
+// fileTransport answers every request with the XML file.
+type fileTransport string
+
+func (f fileTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, err := os.ReadFile(string(f))
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": {"text/xml"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    r,
+	}, nil
+}
+
+// stubProvider publishes copies of its valutes, in rubles, for every
+// requested date.
+type stubProvider []Valute
+
+func (stubProvider) Name() string {
+	return "stub"
+}
+
+func (stubProvider) Base() string {
+	return rubCurrency
+}
+
+func (stubProvider) URL(client *currency.Client, t time.Time) string {
+	return "stub:" + ratesKey(t)
+}
+
+func (p stubProvider) Rates(ctx context.Context, client *currency.Client, t time.Time, fallback bool, cond currency.Validators) (valutes []*Valute, date time.Time, _ currency.Validators, err error) {
+	for _, v := range p {
+		v := v
+		valutes = append(valutes, &v)
+	}
+	return valutes, t, currency.Validators{}, nil
+}
+
+// testProvider publishes the rates of testdata/daily.xml.
+var testProvider = stubProvider{
+	{ID: "R01235", NumCode: 840, CharCode: "USD", Nominal: 1, Name: "Доллар США", Value: "92,5012"},
+	{ID: "R01239", NumCode: 978, CharCode: "EUR", Nominal: 1, Name: "Евро", Value: "100,1234"},
+	{ID: "R01820", NumCode: 392, CharCode: "JPY", Nominal: 100, Name: "Японских иен", Value: "62,3456"},
+}
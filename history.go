@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"currency/currency"
+)
+
+// historyProvider fetches the rate history of a currency in one request.
+type historyProvider interface {
+	History(ctx context.Context, client *currency.Client, id string, from, to time.Time) ([]currency.Record, error)
+}
+
+// loadHistory fills the in-memory rates of a single currency for the dates
+// from its rate history, so a date range costs one request instead of one
+// per day. Dates in memory or in the cache, as a whole day or from an
+// earlier history, are not requested again, and the loaded rates are
+// cached. Dates the history has no rate for are left to the daily rates.
+func (a *App) loadHistory(ctx context.Context, code string, dates []time.Time, skipCache bool) error {
+	p, ok := a.opts.provider.(historyProvider)
+	if !ok || a.opts.offline || a.opts.quoteBase() != a.opts.provider.Base() || len(dates) == 0 {
+		return nil
+	}
+
+	// a mirror of the daily rates may not serve the history
+	if a.client.URLTemplate != urlTemplate {
+		return nil
+	}
+
+	var missing []time.Time
+	for _, d := range dates {
+		cached, err := a.cachedHistoryDay(code, d, skipCache)
+		if err != nil {
+			return err
+		}
+		if !cached {
+			missing = append(missing, d)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// the last day gives the identifier and the name of the currency
+	var last = missing[len(missing)-1]
+	day, err := a.dayRatesCache(ctx, last, skipCache)
+	if err != nil {
+		return err
+	}
+
+	_, err = day.row(code, last)
+	if err != nil {
+		return err
+	}
+
+	tmpl, ok := day.valutes[day.code(code)]
+	if !ok || tmpl.ID == "" {
+		return errors.New("no CBR identifier for " + code)
+	}
+
+	var from = missing[0]
+	if a.opts.fallback {
+		from = from.AddDate(0, 0, -maxFallbackDays)
+	}
+
+	records, err := p.History(ctx, a.client, tmpl.ID, from, last)
+	if err != nil {
+		return err
+	}
+
+	var published = make([]time.Time, len(records))
+	for i, rec := range records {
+		published[i], err = time.ParseInLocation(outputDateFormat, rec.Date, last.Location())
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, d := range missing {
+		if _, ok := a.memDay(d); ok {
+			continue
+		}
+
+		// the latest record published on or before d
+		var found = -1
+		for i, date := range published {
+			if date.After(d) {
+				break
+			}
+			found = i
+		}
+
+		if found < 0 || (!a.opts.fallback && !published[found].Equal(d)) ||
+			d.Sub(published[found]) > maxFallbackDays*24*time.Hour {
+			continue
+		}
+
+		var v = *tmpl
+		v.Nominal = records[found].Nominal
+		v.Value = records[found].Value
+		_, err = a.loadRates(d, []*Valute{&v}, published[found], origin{from: fromHistory, at: now()})
+		if err != nil {
+			return err
+		}
+
+		rates, err := newCachedRates([]*Valute{&v})
+		if err != nil {
+			return err
+		}
+		err = a.writeCacheKey(a.historyCacheKey(code, d), d, cachedDay{Version: cacheVersion, Date: published[found], CachedAt: now(), Rates: rates})
+		if err != nil {
+			return &CacheError{Err: err}
+		}
+	}
+
+	return nil
+}
+
+// cachedHistoryDay reports whether the rate of the currency for d is in
+// memory or fresh in the cache. A rate cached from an earlier history is
+// loaded into memory, the whole days are left to the daily rates.
+func (a *App) cachedHistoryDay(code string, d time.Time, skipCache bool) (bool, error) {
+	if _, ok := a.memDay(d); ok {
+		return true, nil
+	}
+	if skipCache {
+		return false, nil
+	}
+
+	day, ok, err := a.readCachedDay(d)
+	if err != nil {
+		return false, &CacheError{Err: err}
+	}
+	if ok && !day.expired(d, a.opts.cacheTTL) {
+		return true, nil
+	}
+
+	day, ok, err = a.readCacheKey(a.historyCacheKey(code, d))
+	if err != nil {
+		return false, &CacheError{Err: err}
+	}
+	if !ok || day.expired(d, a.opts.cacheTTL) {
+		return false, nil
+	}
+
+	_, err = a.loadRates(d, day.valutes(), day.Date, origin{from: fromCache, at: day.CachedAt})
+	return err == nil, err
+}
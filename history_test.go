@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// pathTransport answers the requests with the XML file of their path and
+// records the paths.
+type pathTransport struct {
+	files map[string]string
+	paths []string
+}
+
+func (p *pathTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	p.paths = append(p.paths, r.URL.Path)
+	return fileTransport(p.files[r.URL.Path]).RoundTrip(r)
+}
+
+func TestLoadHistory(t *testing.T) {
+	var transport = &pathTransport{files: map[string]string{
+		"/scripts/XML_daily.asp":   "testdata/daily.xml",
+		"/scripts/XML_dynamic.asp": "testdata/dynamic.xml",
+	}}
+	var app = newTestApp(t)
+	app.client = newTestClient(&http.Client{Transport: transport}, urlTemplate)
+
+	var dates []time.Time
+	for d := testDate.AddDate(0, 0, -4); !d.After(testDate); d = d.AddDate(0, 0, 1) {
+		dates = append(dates, d)
+	}
+
+	var ctx = context.Background()
+	err := app.loadHistory(ctx, "usd", dates, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := app.collectRows(ctx, dates, []string{"usd"}, false, true, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the weekend and the Monday fall back to Friday, they are not listed
+	var want = [][]string{
+		{"13.10.2026", "USD", "91.50", "RUB", "1"},
+		{"14.10.2026", "USD", "92.50", "RUB", "1"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+
+	// the last day for the identifier, then the history in one request
+	if want := []string{"/scripts/XML_daily.asp", "/scripts/XML_dynamic.asp"}; !reflect.DeepEqual(transport.paths, want) {
+		t.Errorf("requests = %v, want %v", transport.paths, want)
+	}
+}
+
+func TestLoadHistoryCached(t *testing.T) {
+	clock(t, 12)
+	var transport = &pathTransport{files: map[string]string{
+		"/scripts/XML_daily.asp":   "testdata/daily.xml",
+		"/scripts/XML_dynamic.asp": "testdata/dynamic.xml",
+	}}
+	var app = newTestApp(t)
+	app.client = newTestClient(&http.Client{Transport: transport}, urlTemplate)
+
+	var dates []time.Time
+	for d := testDate.AddDate(0, 0, -4); !d.After(testDate); d = d.AddDate(0, 0, 1) {
+		dates = append(dates, d)
+	}
+
+	var ctx = context.Background()
+	var run = func(app *App) [][]string {
+		t.Helper()
+		transport.paths = nil
+		err := app.loadHistory(ctx, "usd", dates, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := app.collectRows(ctx, dates, []string{"usd"}, false, true, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+
+	var want = run(app)
+
+	// the rates loaded from the history and the last day are cached
+	if got := run(reopen(app)); !reflect.DeepEqual(got, want) {
+		t.Errorf("cached rows = %v, want %v", got, want)
+	}
+	if len(transport.paths) != 0 {
+		t.Errorf("requests for a cached range = %v, want none", transport.paths)
+	}
+
+	// whole days in the cache are not requested either
+	_, err := app.clearCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range dates {
+		err = app.writeCachedDay(d, cachedDay{Version: cacheVersion, Date: d, CachedAt: now(), Rates: []cachedRate{{Code: "USD", Nominal: 1, Value: 90}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	transport.paths = nil
+	err = reopen(app).loadHistory(ctx, "usd", dates, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.paths) != 0 {
+		t.Errorf("requests for cached days = %v, want none", transport.paths)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var listHeader = []string{"code", "name", "nominal"}
+
+// listCurrencies returns a row per currency published for the date t,
+// loading the day from the cache when possible. By input keeps the order
+// of the provider. Only the currencies with a rate per unit of the
+// provider base within the band are listed.
+func (a *App) listCurrencies(ctx context.Context, t time.Time, sortBy string, band rateBand, skipCache bool) (rows [][]string, err error) {
+	var o = a.opts.rows
+	var less func(a, b *Valute) bool
+	switch sortBy {
+	case sortByInput:
+		less = func(a, b *Valute) bool { return false }
+	case sortByCode:
+		less = func(a, b *Valute) bool { return a.CharCode < b.CharCode }
+	case sortByName:
+		less = func(a, b *Valute) bool {
+			return localizedName(a.CharCode, a.Name, o.lang) < localizedName(b.CharCode, b.Name, o.lang)
+		}
+	default:
+		return nil, &UsageError{Err: fmt.Errorf("unknown sort order '%s', expected input, code or name", sortBy)}
+	}
+
+	day, err := a.dayRatesCache(ctx, t, skipCache)
+	if err != nil {
+		return
+	}
+
+	// the provider base is added to the day for a --base, it is not listed
+	var valutes []*Valute
+	for _, code := range day.order {
+		if code != a.opts.provider.Base() {
+			valutes = append(valutes, day.valutes[code])
+		}
+	}
+
+	sort.SliceStable(valutes, func(i, j int) bool {
+		return less(valutes[i], valutes[j])
+	})
+
+	for _, val := range valutes {
+		if band.bounded() {
+			value, err := val.getRawValue()
+			if err != nil || !band.contains(value/float64(val.Nominal)) {
+				continue
+			}
+		}
+
+		rows = append(rows, []string{
+			o.caseCode(val.CharCode),
+			localizedName(val.CharCode, val.Name, o.lang),
+			strconv.FormatInt(val.Nominal, 10),
+		})
+	}
+
+	return
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestListCurrencies(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	var tests = []struct {
+		sortBy string
+		want   string
+	}{
+		{
+			sortBy: sortByCode,
+			want:   "EUR\tЕвро\t1\nJPY\tЯпонских иен\t100\nUSD\tДоллар США\t1\n",
+		},
+		{
+			sortBy: sortByName,
+			want:   "USD\tДоллар США\t1\nEUR\tЕвро\t1\nJPY\tЯпонских иен\t100\n",
+		},
+		{
+			sortBy: sortByInput,
+			want:   "USD\tДоллар США\t1\nEUR\tЕвро\t1\nJPY\tЯпонских иен\t100\n",
+		},
+	}
+
+	var app = cbr.app(t)
+	for _, tt := range tests {
+		rows, err := app.listCurrencies(context.Background(), testDate, tt.sortBy, anyRate, false)
+		if err != nil {
+			t.Fatalf("list by %s: %v", tt.sortBy, err)
+		}
+
+		var buf bytes.Buffer
+		err = app.writeTable(&buf, tsvFormat, listHeader, rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("list by %s = %q, want %q", tt.sortBy, got, tt.want)
+		}
+	}
+}
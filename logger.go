@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+)
+
+type logLevel int
+
+const (
+	// levelQuiet only lets fatal errors through.
+	levelQuiet logLevel = iota
+	levelError
+	levelInfo
+	levelDebug
+)
+
+const (
+	textLogFormat = "text"
+	jsonLogFormat = "json"
+)
+
+// leveledLogger writes messages up to its level. Fatal errors are always
+// written. With a JSON handler every message is a JSON line carrying the
+// level, the message and the fields added by with.
+type leveledLogger struct {
+	level logLevel
+	out   *log.Logger
+	json  *slog.Logger
+}
+
+func newLogger(w io.Writer, level logLevel) *leveledLogger {
+	return &leveledLogger{
+		level: level,
+		out:   log.New(w, "", log.LstdFlags),
+	}
+}
+
+func newJSONLogger(w io.Writer, level logLevel) *leveledLogger {
+	var handler = slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return &leveledLogger{
+		level: level,
+		out:   log.New(w, "", log.LstdFlags),
+		json:  slog.New(handler),
+	}
+}
+
+// with returns a logger adding the key-value pairs as fields of JSON log
+// lines. Text log lines are unchanged, their messages hold the values.
+func (l *leveledLogger) with(args ...interface{}) *leveledLogger {
+	var c = *l
+	if c.json != nil {
+		c.json = c.json.With(args...)
+	}
+	return &c
+}
+
+func (l *leveledLogger) logf(level logLevel, severity slog.Level, prefix string, format string, args ...interface{}) {
+	if level > l.level {
+		return
+	}
+
+	if l.json != nil {
+		l.json.Log(context.Background(), severity, fmt.Sprintf(format, args...))
+		return
+	}
+	l.out.Print(prefix + fmt.Sprintf(format, args...))
+}
+
+func (l *leveledLogger) Debugf(format string, args ...interface{}) {
+	l.logf(levelDebug, slog.LevelDebug, "debug: ", format, args...)
+}
+
+func (l *leveledLogger) Infof(format string, args ...interface{}) {
+	l.logf(levelInfo, slog.LevelInfo, "", format, args...)
+}
+
+func (l *leveledLogger) Warnf(format string, args ...interface{}) {
+	l.logf(levelError, slog.LevelWarn, "warning: ", format, args...)
+}
+
+func (l *leveledLogger) Errorf(format string, args ...interface{}) {
+	l.logf(levelError, slog.LevelError, "error: ", format, args...)
+}
+
+// Fail writes a fatal error, whatever the level.
+func (l *leveledLogger) Fail(v ...interface{}) {
+	if l.json != nil {
+		l.json.Error(fmt.Sprint(v...), "fatal", true)
+		return
+	}
+	l.out.Print(v...)
+}
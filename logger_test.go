@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoggerLevels(t *testing.T) {
+	var tests = []struct {
+		level logLevel
+		want  []string
+	}{
+		{level: levelQuiet, want: []string{"fatal"}},
+		{level: levelError, want: []string{"warning: warn", "error: error", "fatal"}},
+		{level: levelInfo, want: []string{"info", "warning: warn", "error: error", "fatal"}},
+		{level: levelDebug, want: []string{"debug: debug", "info", "warning: warn", "error: error", "fatal"}},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		var l = newLogger(&buf, tt.level)
+		l.Debugf("debug")
+		l.Infof("info")
+		l.Warnf("warn")
+		l.Errorf("error")
+		l.Fail(errors.New("fatal"))
+
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			// drop the date and time
+			fields := strings.SplitN(line, " ", 3)
+			got = append(got, fields[2])
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("level %d logged %q, want %q", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestJSONLogs(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	var stderr = capture(t, &os.Stderr, func() {
+		code, _ := runCLI(t, "--url-template", cbr.template(), "--log-format", "json", "--verbose", "--currency", "usd", "--date", "14.10.2026")
+		if code != exitOK {
+			t.Errorf("exit code = %d, want %d", code, exitOK)
+		}
+	})
+
+	// every line is a JSON object, the fetch one carries the URL and the
+	// cache miss the date
+	var fetched, missed bool
+	var url = strings.Replace(cbr.template(), "%s", "14/10/2026", 1)
+	for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+		var entry map[string]interface{}
+		err := json.Unmarshal([]byte(line), &entry)
+		if err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		for _, key := range []string{"time", "level", "msg"} {
+			if _, ok := entry[key]; !ok {
+				t.Errorf("log line %q has no %s", line, key)
+			}
+		}
+
+		if entry["url"] == url {
+			fetched = true
+			if entry["level"] != "DEBUG" || entry["msg"] != "GET "+url {
+				t.Errorf("fetch log line = %v, want a DEBUG GET of %s", entry, url)
+			}
+		}
+		if entry["date"] == "14.10.2026" {
+			missed = true
+		}
+	}
+	if !fetched {
+		t.Errorf("logs = %q, want a line with the url %s", stderr, url)
+	}
+	if !missed {
+		t.Errorf("logs = %q, want a line with the date", stderr)
+	}
+}
@@ -1,248 +1,520 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
-	"encoding/xml"
+	"context"
 	"errors"
-	"flag"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
-	bolt "go.etcd.io/bbolt"
-	"golang.org/x/text/encoding/charmap"
+	"currency/currency"
+
+	"golang.org/x/text/language"
 )
 
 const (
-	urlTemplate       = "https://www.cbr.ru/scripts/XML_daily.asp?date_req=%s"
+	urlTemplate       = currency.URLTemplate
 	urlDateTimeFormat = "2006-01-02T15:04:05"
 	outputDateFormat  = "02.01.2006"
-	xmlDateFormat     = "02/01/2006"
+	xmlDateFormat     = currency.DateFormat
 
 	usdCurrency = "usd"
 	eurCurrency = "eur"
 	uahCurrency = "uah"
+	rubCurrency = "rub"
+
+	maxFallbackDays = currency.MaxFallbackDays
+
+	defaultPrecision = 2
+	minPrecision     = 0
+	maxPrecision     = 10
+
+	// defaultMaxRate is far above any published rate per unit, even
+	// against a weak --base currency
+	defaultMaxRate = 1e6
 
-	userAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Safari/537.36"
+	listCommand    = "list"
+	cacheCommand   = "cache"
+	serveCommand   = "serve"
+	clearCommand   = "clear"
+	statsCommand   = "stats"
+	dumpCommand    = "dump"
+	versionCommand = "version"
+	latestCommand  = "latest"
+
+	defaultUserAgent = currency.DefaultUserAgent
 )
 
 var (
-	httpClient = http.Client{
-		Timeout: time.Second * 2, // Timeout after 2 seconds
-	}
-	cachePath      = filepath.Join(os.Getenv("HOME"), ".cache", "currency", "cache")
-	cacheStorage   *bolt.DB
-	currenciesRate = map[string][]string{}
+	logger = newLogger(os.Stderr, levelInfo)
+	now    = time.Now
 )
 
+// Valute is a published currency rate.
 type Valute struct {
-	XMLName  xml.Name `xml:"Valute"`
-	ID       string   `xml:"ID,attr"`
-	NumCode  int64    `xml:"NumCode"`
-	CharCode string   `xml:"CharCode"`
-	Nominal  int64    `xml:"Nominal"`
-	Name     string   `xml:"Name"`
-	Value    string   `xml:"Value"`
+	ID       string
+	NumCode  int64
+	CharCode string
+	Nominal  int64
+	Name     string
+	Value    string
 	Date     time.Time
+	Base     string
+	// baseValue is the provider base value of a unit of the quote base,
+	// zero when the rate is quoted against the provider base.
+	baseValue float64
 }
 
-type ValCurs struct {
-	XMLName xml.Name  `xml:"ValCurs"`
-	Date    string    `xml:"Date,attr"`
-	Name    string    `xml:"name,attr"`
-	Valutes []*Valute `xml:"Valute"`
+func (v Valute) getRawValue() (val float64, err error) {
+	val, err = currency.ParseValue(v.Value)
+	if err != nil {
+		err = fmt.Errorf("invalid value '%s' for %s: %w", v.Value, v.CharCode, err)
+	}
+	return
 }
 
-func (v Valute) getRow() (row []string, err error) {
-	valStr := strings.Replace(v.Value, ",", ".", -1)
-	val, err := strconv.ParseFloat(valStr, 64)
+func (v Valute) getValue() (val float64, err error) {
+	val, err = v.getRawValue()
 	if err != nil {
 		return
 	}
 
 	divOn := float64(v.Nominal)
+	if v.baseValue != 0 {
+		divOn *= v.baseValue
+	}
 
-	return []string{
-		v.Date.Format(outputDateFormat),
-		strings.ToUpper(v.CharCode),
-		fmt.Sprintf("%.2f", val/divOn),
-	}, err
+	return val / divOn, nil
 }
 
-func getCurrencyRates(t time.Time) (out map[string][]string, err error) {
-	if len(currenciesRate) > 0 {
-		return currenciesRate, nil
+// checkValue reports a rate per unit that is not positive or above max,
+// an error in the strict mode and a warning otherwise.
+func (v Valute) checkValue(max float64, strict bool) error {
+	val, err := v.getValue()
+	if err != nil {
+		return err
+	}
+	if val > 0 && val <= max {
+		return nil
 	}
 
-	var url = fmt.Sprintf(urlTemplate, t.Format(xmlDateFormat))
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return
+	err = &ImplausibleRateError{Code: strings.ToUpper(v.CharCode), Date: v.Date, Value: val, Max: max}
+	if strict {
+		return err
 	}
+	logger.with("currency", strings.ToLower(v.CharCode), "date", ratesKey(v.Date)).Warnf("%v", err)
+	return nil
+}
 
-	req.Header.Set("User-Agent", userAgent)
+// validateRate checks the rate of the currency emitted from the day when
+// --validate is set, the other published rates are not.
+func (a *App) validateRate(name string, day *dayRates) error {
+	if !a.opts.rows.validate {
+		return nil
+	}
 
-	res, err := httpClient.Do(req)
-	if err != nil {
-		return
+	val, ok := day.valutes[day.code(name)]
+	if !ok {
+		return nil
 	}
+	return val.checkValue(a.opts.rows.maxRate, a.opts.strict)
+}
+
+// rowOptions controls the columns of the rows built by getRow.
+type rowOptions struct {
+	// withName appends the currency name column.
+	withName bool
+	// withID appends the provider's own currency identifier column.
+	withID bool
+	// withInverse appends the units of the currency per unit of the base.
+	withInverse bool
+	// raw emits the published value for Nominal units instead of the
+	// rate per unit.
+	raw bool
+	// precision is the number of decimal places of the rate per unit.
+	precision int
+	// sigFigs are the significant digits of the rate per unit instead of
+	// the precision when not zero.
+	sigFigs int
+	// roundMode rounds the rates to the precision, see roundHalfUp.
+	roundMode string
+	// trimZeros drops the trailing zeros left by the precision, 90.50
+	// becomes 90.5 and 90.00 becomes 90.
+	trimZeros bool
+	// withRequestedDate appends the requested date column, the date
+	// column holds the publication date.
+	withRequestedDate bool
+	// withChange names the change columns appended by appendChange.
+	withChange bool
+	// dateFormat is the layout of the written date columns, the rows
+	// keep outputDateFormat dates.
+	dateFormat string
+	// validate checks that the rate per unit is positive and at most
+	// maxRate, see checkValue.
+	validate bool
+	maxRate  float64
+	// codeCase is the case of the emitted currency codes, see caseCode.
+	codeCase string
+	// fields are the written columns in order, every column when empty,
+	// see selectFields.
+	fields []string
+	// lang is the language of the currency names, the published Russian
+	// names when not set, see localizedName.
+	lang language.Tag
+}
 
-	if res.Body == nil {
-		return out, errors.New("Response body are empty")
+func validatePrecision(precision int) error {
+	if precision < minPrecision || precision > maxPrecision {
+		return fmt.Errorf("precision %d is out of range %d-%d", precision, minPrecision, maxPrecision)
 	}
+	return nil
+}
 
-	defer res.Body.Close()
-	if res.StatusCode != http.StatusOK {
-		err = fmt.Errorf("status code error: %s", res.Status)
-		return
+// format formats a rate or a change with the precision, or the
+// significant figures when set.
+func (o rowOptions) format(val float64) string {
+	var s = strconv.FormatFloat(val, 'f', o.precision, 64)
+	switch {
+	case o.sigFigs > 0:
+		s = roundSigFigs(val, o.sigFigs, o.roundMode)
+	case o.roundMode == roundHalfUp:
+		s = roundUp(val, o.precision)
+	}
+	if o.trimZeros && strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+		if s == "-0" {
+			s = "0"
+		}
 	}
+	return s
+}
 
-	var v ValCurs
-	d := xml.NewDecoder(res.Body)
-	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
-		switch charset {
-		case "windows-1251":
-			return charmap.Windows1251.NewDecoder().Reader(input), nil
-		default:
-			return nil, fmt.Errorf("unknown charset: %s", charset)
+// header names the columns of the rows built with the options.
+func (o rowOptions) header() []string {
+	var header = []string{"date", "code", "rate", "base", "nominal"}
+	if o.withName {
+		header = append(header, "name")
+	}
+	if o.withID {
+		header = append(header, "id")
+	}
+	if o.withInverse {
+		header = append(header, "inverse")
+	}
+	if o.withRequestedDate {
+		header = append(header, "requested_date")
+	}
+	if o.withChange {
+		header = append(header, "change", "change_percent")
+	}
+	return header
+}
+
+func (v Valute) getRow(o rowOptions) (row []string, err error) {
+	var rate string
+	if o.raw {
+		val, err := v.getRawValue()
+		if err != nil {
+			return nil, err
+		}
+		if v.baseValue != 0 {
+			val /= v.baseValue
+		}
+		rate = strconv.FormatFloat(val, 'f', -1, 64)
+	} else {
+		val, err := v.getValue()
+		if err != nil {
+			return nil, err
 		}
+		rate = o.format(val)
 	}
-	err = d.Decode(&v)
-	if err != nil {
-		return
+
+	row = []string{
+		v.Date.Format(outputDateFormat),
+		o.caseCode(v.CharCode),
+		rate,
+		o.caseCode(strings.ToUpper(v.Base)),
+		strconv.FormatInt(v.Nominal, 10),
 	}
 
-	for _, val := range v.Valutes {
-		val.Date = t
-		row, err := val.getRow()
+	if o.withName {
+		row = append(row, localizedName(v.CharCode, v.Name, o.lang))
+	}
+	if o.withID {
+		row = append(row, v.ID)
+	}
+	if o.withInverse {
+		val, err := v.getValue()
 		if err != nil {
-			return out, err
+			return nil, err
 		}
-		currenciesRate[strings.ToLower(val.CharCode)] = row
+
+		// a zero rate has no reciprocal, the cell is left blank
+		var inverse string
+		if val != 0 {
+			inverse = o.format(1 / val)
+		}
+		row = append(row, inverse)
 	}
 
-	return currenciesRate, nil
+	return row, err
 }
 
-func getCurrencyRate(name string, t time.Time) (out []string, err error) {
-	vals, err := getCurrencyRates(t)
-	if err != nil {
-		return
+// quoteBase is the currency the rates are quoted against.
+func (o options) quoteBase() string {
+	if o.base == "" {
+		return o.provider.Base()
+	}
+	return o.base
+}
+
+// crossValue returns the provider base value of a unit of the quote base.
+func (o options) crossValue(valutes []*Valute, t time.Time) (val float64, err error) {
+	var base = o.quoteBase()
+	if base == o.provider.Base() {
+		return 1, nil
 	}
 
-	if val, ok := vals[strings.ToLower(name)]; ok {
-		return val, nil
+	for _, v := range valutes {
+		if strings.ToLower(v.CharCode) == base {
+			return v.getValue()
+		}
 	}
 
-	err = fmt.Errorf("cannot get currency rate for '%s'", name)
+	err = &CurrencyNotFoundError{Code: base, Date: t}
 	return
 }
 
-func getCurrencyItemCache(name string, t time.Time, skipCache bool) (r []string, err error) {
-	var cacheKey = fmt.Sprintf("%s-%s", t.Format(outputDateFormat), name)
-	var val []byte
-	var tx *bolt.Tx
+func ratesKey(t time.Time) string {
+	return t.Format(outputDateFormat)
+}
 
-	tx, err = cacheStorage.Begin(true)
+// loadRates fills the in-memory rates for the requested date from the
+// valutes published for t, quoted against the quote base, and returns them.
+func (a *App) loadRates(requested time.Time, valutes []*Valute, t time.Time, o origin) (*dayRates, error) {
+	cross, err := a.opts.crossValue(valutes, t)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	defer tx.Rollback()
-
-	var b *bolt.Bucket
-	b, err = tx.CreateBucketIfNotExists([]byte("cache"))
+	var provider = a.opts.provider
+	var day = &dayRates{
+		date:    t,
+		rows:    map[string][]string{},
+		values:  map[string]float64{provider.Base(): 1 / cross},
+		codes:   map[string]string{},
+		valutes: map[string]*Valute{},
+		origin:  o,
+	}
+	if cross != 1 {
+		// the provider base is not published, quote it against the base too
+		valutes = append(valutes[:len(valutes):len(valutes)], &Valute{
+			CharCode: strings.ToUpper(provider.Base()),
+			Nominal:  1,
+			Value:    "1",
+		})
+	}
+	err = a.opts.checkDuplicates(valutes, t)
 	if err != nil {
-		return
+		return nil, err
 	}
+	for _, val := range valutes {
+		val.Date = t
+		val.Base = a.opts.quoteBase()
+		if cross != 1 {
+			val.baseValue = cross
+		}
+		row, err := val.getRow(a.opts.rows)
+		if err != nil {
+			return nil, err
+		}
+		if a.opts.rows.withRequestedDate {
+			row = append(row, ratesKey(requested))
+		}
+		value, err := val.getValue()
+		if err != nil {
+			return nil, err
+		}
+		day.rows[strings.ToLower(val.CharCode)] = row
+		day.values[strings.ToLower(val.CharCode)] = value
+		if _, ok := day.valutes[strings.ToLower(val.CharCode)]; !ok {
+			day.order = append(day.order, strings.ToLower(val.CharCode))
+		}
+		day.valutes[strings.ToLower(val.CharCode)] = val
+		if val.NumCode != 0 {
+			day.codes[fmt.Sprintf("%03d", val.NumCode)] = strings.ToLower(val.CharCode)
+		}
+	}
+
+	a.mem.set(requested, day, o.at)
+	return day, nil
+}
 
-	if skipCache {
-		goto skipCache
+// checkDuplicates reports the currency codes published more than once,
+// an error in the strict mode and a warning otherwise, the last valute
+// with the code wins.
+func (o options) checkDuplicates(valutes []*Valute, t time.Time) error {
+	var ids = map[string][]string{}
+	var codes []string
+	for _, val := range valutes {
+		code := strings.ToLower(val.CharCode)
+		if _, ok := ids[code]; !ok {
+			codes = append(codes, code)
+		}
+		ids[code] = append(ids[code], val.ID)
 	}
 
-	val = b.Get([]byte(cacheKey))
-	if val == nil {
-		goto skipCache
+	for _, code := range codes {
+		if len(ids[code]) < 2 {
+			continue
+		}
+
+		err := &DuplicateCodeError{Code: strings.ToUpper(code), Date: t, IDs: ids[code]}
+		if o.strict {
+			return err
+		}
+		logger.with("currency", code, "date", ratesKey(t)).Warnf("%v, using %s", err, ids[code][len(ids[code])-1])
 	}
+	return nil
+}
 
-	err = json.Unmarshal(val, &r)
+func (a *App) getCurrencyRates(ctx context.Context, t time.Time) (out map[string][]string, err error) {
+	day, err := a.dayRatesCache(ctx, t, false)
 	if err != nil {
 		return
 	}
 
-	return
+	return day.rows, nil
+}
 
-skipCache:
-	r, err = getCurrencyRate(name, t)
+// getCurrencyRate returns the row of the currency by alphabetic or numeric
+// code.
+func (a *App) getCurrencyRate(ctx context.Context, name string, t time.Time) (out []string, err error) {
+	day, err := a.dayRatesCache(ctx, t, false)
 	if err != nil {
 		return
 	}
 
-	val, err = json.Marshal(r)
+	return day.row(name, t)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run executes the command line and returns the process exit code.
+func run(args []string) (code int) {
+	var c = newCLI()
+	err := c.parse(args)
+	var usageErr *UsageError
+	if errors.As(err, &usageErr) {
+		return failure(err)
+	}
 	if err != nil {
-		return
+		return parseFailure(err)
+	}
+
+	if c.version || c.command == versionCommand {
+		err = writeVersion(os.Stdout)
+		if err != nil {
+			return failure(err)
+		}
+		return exitOK
+	}
+
+	// latest prints the rates of today or, walking back with the fallback,
+	// of the most recent day they were published for
+	if c.command == latestCommand {
+		if c.date != "" || c.since != "" || isFlagSet(c.fs, "days-before") || c.fromDate != "" || c.toDate != "" || c.compare != "" || c.batch || c.noFallback {
+			return failure(usageErrorf("the %s command takes no dates and always falls back to the published day", latestCommand))
+		}
+		c.command = ""
 	}
 
-	err = b.Put([]byte(cacheKey), val)
+	err = c.setupLogger()
 	if err != nil {
-		return
+		return failure(err)
 	}
 
-	err = tx.Commit()
+	err = c.applyConfig()
 	if err != nil {
-		return
+		return failure(err)
 	}
 
-	return
-}
+	req, err := c.request()
+	if err != nil {
+		return failure(err)
+	}
 
-func main() {
-	var (
-		currency   = flag.String("currency", usdCurrency, "currency code")
-		skipCache  = flag.Bool("skip-cache", false, "skip cache")
-		daysBefore = flag.Int("days-before", 0, "get currency rate in date x days before")
-		rows       [][]string
-		err        error
-	)
-	flag.Parse()
+	opts, err := c.options()
+	if err != nil {
+		return failure(err)
+	}
 
-	err = os.MkdirAll(filepath.Dir(cachePath), 0777)
+	client, err := c.client(opts.provider.Name())
 	if err != nil {
-		log.Fatal(err)
+		return failure(err)
 	}
 
-	cacheStorage, err = bolt.Open(cachePath, 0600, nil)
+	err = c.checkLimits()
 	if err != nil {
-		log.Fatal(err)
+		return failure(err)
 	}
 
-	defer cacheStorage.Close()
+	out, err := openOutput(c.outputs, c.appendOut)
+	if err != nil {
+		return failure(err)
+	}
 
-	currenciesList := strings.Split(*currency, ",")
-	if len(currenciesList) == 0 {
-		log.Fatal("select at least one currency")
+	defer func() {
+		err := out.Close()
+		if err != nil && code == exitOK {
+			code = failure(err)
+		}
+	}()
+
+	opts.colorize = useColor(c.colorMode, out)
+
+	req.currencies, err = c.currencies(opts)
+	if err != nil {
+		return failure(err)
+	}
+
+	if c.dryRun {
+		err = c.printURLs(out, opts.provider, client, req.dates)
+		if err != nil {
+			return failure(err)
+		}
+		return exitOK
+	}
+
+	db, cachePath, err := c.openCache()
+	if err != nil {
+		return failure(err)
 	}
 
-	var date = time.Now().Add(time.Duration(-*daysBefore) * 24 * time.Hour)
-	for _, curr := range currenciesList {
-		row, err := getCurrencyItemCache(curr, date, *skipCache)
+	defer db.Close()
+
+	var app = newApp(db, client, newMemoryRates(c.memSize), opts)
+
+	// a read-only cache keeps even the legacy entries
+	if opts.cacheWrites {
+		err = app.migrateCache()
 		if err != nil {
-			log.Fatal(err)
+			return failure(&CacheError{Err: err})
 		}
-		rows = append(rows, row)
 	}
 
-	var writer = csv.NewWriter(os.Stdout)
-	writer.Comma = '\t'
-	err = writer.WriteAll(rows)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = c.execute(ctx, app, out, req, cachePath)
 	if err != nil {
-		log.Fatal(err)
+		return failure(err)
 	}
+	return exitOK
 }
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNameColumn(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	var want = map[string]string{
+		"usd": "Доллар США",
+		"eur": "Евро",
+		"jpy": "Японских иен",
+	}
+
+	var app = cbr.app(t)
+	app.opts.rows.withName = true
+	for code, name := range want {
+		row, err := app.getCurrencyItemCache(context.Background(), code, testDate, false)
+		if err != nil {
+			t.Fatalf("%s: %v", code, err)
+		}
+		if got := row[len(row)-1]; got != name {
+			t.Errorf("%s name = %q, want %q", code, got, name)
+		}
+	}
+}
+
+func TestRawRows(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	var tests = []struct {
+		raw  bool
+		want map[string][]string
+	}{
+		{
+			want: map[string][]string{
+				"usd": {"14.10.2026", "USD", "92.50", "RUB", "1"},
+				"jpy": {"14.10.2026", "JPY", "0.62", "RUB", "100"},
+			},
+		},
+		{
+			raw: true,
+			want: map[string][]string{
+				"usd": {"14.10.2026", "USD", "92.5012", "RUB", "1"},
+				"jpy": {"14.10.2026", "JPY", "62.3456", "RUB", "100"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		var app = cbr.app(t)
+		app.opts.rows.raw = tt.raw
+		for code, want := range tt.want {
+			row, err := app.getCurrencyItemCache(context.Background(), code, testDate, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(row, want) {
+				t.Errorf("raw %v: %s row = %v, want %v", tt.raw, code, row, want)
+			}
+		}
+	}
+}
+
+func TestPrecision(t *testing.T) {
+	var idr = Valute{CharCode: "IDR", Nominal: 10000, Value: "56,1234", Date: testDate, Base: rubCurrency}
+	var vnd = Valute{CharCode: "VND", Nominal: 10000, Value: "36,9876", Date: testDate, Base: rubCurrency}
+
+	var tests = []struct {
+		v         Valute
+		precision int
+		want      string
+	}{
+		{v: idr, precision: defaultPrecision, want: "0.01"},
+		{v: idr, precision: 6, want: "0.005612"},
+		{v: idr, precision: 10, want: "0.0056123400"},
+		{v: vnd, precision: 0, want: "0"},
+		{v: vnd, precision: 6, want: "0.003699"},
+	}
+
+	for _, tt := range tests {
+		row, err := tt.v.getRow(rowOptions{precision: tt.precision})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row[2] != tt.want {
+			t.Errorf("%s at precision %d = %s, want %s", tt.v.CharCode, tt.precision, row[2], tt.want)
+		}
+	}
+}
+
+func TestValidatePrecision(t *testing.T) {
+	for _, p := range []int{minPrecision, defaultPrecision, maxPrecision} {
+		if err := validatePrecision(p); err != nil {
+			t.Errorf("precision %d: %v", p, err)
+		}
+	}
+	for _, p := range []int{minPrecision - 1, maxPrecision + 1} {
+		if err := validatePrecision(p); err == nil {
+			t.Errorf("precision %d accepted", p)
+		}
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+	failing, _ := failingServer(t, http.StatusInternalServerError, 100)
+
+	var tests = []struct {
+		name string
+		args []string
+		code int
+	}{
+		{name: "rates", args: []string{"--currency", "usd"}, code: exitOK},
+		{name: "help", args: []string{"--help"}, code: exitOK},
+		{name: "unknown flag", args: []string{"--colour"}, code: exitUsage},
+		{name: "unknown format", args: []string{"--format", "yaml"}, code: exitUsage},
+		{name: "unknown command", args: []string{"rates"}, code: exitUsage},
+		{name: "network", args: []string{"--url-template", failing.URL + "/?date_req=%s", "--retries", "0"}, code: exitNetwork},
+		{name: "unknown currency", args: []string{"--currency", "xyz"}, code: exitNotFound},
+		{name: "not cached", args: []string{"--offline"}, code: exitCache},
+		{name: "implausible", args: []string{"--currency", "eur", "--validate", "--validate-max", "95", "--strict"}, code: exitInvalid},
+		// EUR is above the maximum but not emitted
+		{name: "plausible", args: []string{"--currency", "usd", "--validate", "--validate-max", "95", "--strict"}, code: exitOK},
+	}
+
+	for _, tt := range tests {
+		var args = append([]string{"--url-template", cbr.template(), "--date", "14.10.2026"}, tt.args...)
+		if code, _ := runCLI(t, args...); code != tt.code {
+			t.Errorf("%s: exit code = %d, want %d", tt.name, code, tt.code)
+		}
+	}
+}
+
+func TestValidateRate(t *testing.T) {
+	var app = newTestApp(t)
+	app.opts.provider = stubProvider{
+		{CharCode: "USD", Nominal: 1, Value: "92,5012"},
+		{CharCode: "ZER", Nominal: 1, Value: "0"},
+		{CharCode: "NEG", Nominal: 1, Value: "-1,5"},
+		{CharCode: "BIG", Nominal: 10, Value: "20000000"},
+	}
+	app.opts.rows.validate = true
+	app.opts.rows.maxRate = defaultMaxRate
+
+	var tests = []struct {
+		code  string
+		value float64
+	}{
+		{code: "usd"},
+		{code: "zer", value: 0},
+		{code: "neg", value: -1.5},
+		{code: "big", value: 2e6},
+	}
+
+	for _, strict := range []bool{false, true} {
+		app.opts.strict = strict
+		for _, tt := range tests {
+			rows, err := app.collectRows(context.Background(), []time.Time{testDate}, []string{tt.code}, false, false, 1)
+
+			var implausible *ImplausibleRateError
+			switch {
+			case tt.code == "usd" || !strict:
+				if err != nil || len(rows) != 1 {
+					t.Errorf("%s, strict %v: rows = %v, error = %v, want the row", tt.code, strict, rows, err)
+				}
+			case !errors.As(err, &implausible) || implausible.Value != tt.value || exitCode(err) != exitInvalid:
+				t.Errorf("%s, strict %v: error = %v, want an ImplausibleRateError of %v", tt.code, strict, err, tt.value)
+			}
+		}
+	}
+}
+
+func TestValidateRateEvicted(t *testing.T) {
+	var app = newTestApp(t)
+	app.opts.provider = stubProvider{{CharCode: "BIG", Nominal: 1, Value: "2000000"}}
+	app.opts.rows.validate = true
+	app.opts.rows.maxRate = defaultMaxRate
+	app.opts.strict = true
+	// the day is evicted from memory as soon as it is loaded
+	app = newApp(app.db, app.client, newMemoryRates(0), app.opts)
+
+	_, err := app.collectRows(context.Background(), []time.Time{testDate}, []string{"big"}, false, false, 1)
+	var implausible *ImplausibleRateError
+	if !errors.As(err, &implausible) {
+		t.Errorf("error = %v, want an ImplausibleRateError", err)
+	}
+}
+
+func TestInvalidValue(t *testing.T) {
+	for _, value := range []string{"NaN", "1e3", "1,234,56", ""} {
+		var v = Valute{CharCode: "USD", Nominal: 1, Value: value}
+		_, err := v.getRow(rowOptions{precision: defaultPrecision})
+		if err == nil {
+			t.Errorf("value %q accepted", value)
+			continue
+		}
+
+		if want := "invalid value '" + value + "' for USD"; !strings.Contains(err.Error(), want) {
+			t.Errorf("value %q error = %v, want it to contain %q", value, err, want)
+		}
+	}
+}
+
+func TestCrossRates(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	code, out := runCLI(t, "--url-template", cbr.template(), "--base", "usd", "--precision", "6", "--currency", "eur,jpy,rub,usd", "--date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+
+	// 100.1234 / 92.5012, 62.3456 / 100 / 92.5012 and 1 / 92.5012
+	var want = "14.10.2026\tEUR\t1.082401\tUSD\t1\n" +
+		"14.10.2026\tJPY\t0.006740\tUSD\t100\n" +
+		"14.10.2026\tRUB\t0.010811\tUSD\t1\n" +
+		"14.10.2026\tUSD\t1.000000\tUSD\t1\n"
+	if out != want {
+		t.Errorf("--base usd output = %q, want %q", out, want)
+	}
+
+	if code, _ := runCLI(t, "--url-template", cbr.template(), "--base", "gbp", "--currency", "eur", "--date", "14.10.2026"); code != exitNotFound {
+		t.Errorf("exit code for an unpublished base = %d, want %d", code, exitNotFound)
+	}
+}
+
+func TestPublicationDate(t *testing.T) {
+	body, err := os.ReadFile("testdata/daily.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a Sunday request answered with the rates of Saturday
+	body = bytes.Replace(body, []byte(`Date="14.10.2026"`), []byte(`Date="10.10.2026"`), 1)
+
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml; charset=windows-1251")
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	var tests = []struct {
+		args []string
+		want string
+	}{
+		{want: "10.10.2026\tUSD\t92.50\tRUB\t1\n"},
+		{args: []string{"--show-requested-date"}, want: "10.10.2026\tUSD\t92.50\tRUB\t1\t11.10.2026\n"},
+	}
+
+	for _, tt := range tests {
+		var args = append([]string{"--url-template", srv.URL + "/?date_req=%s", "--currency", "usd", "--date", "11.10.2026"}, tt.args...)
+		code, out := runCLI(t, args...)
+		if code != exitOK {
+			t.Fatalf("%v: exit code = %d, want %d", tt.args, code, exitOK)
+		}
+		if out != tt.want {
+			t.Errorf("%v: output = %q, want %q", tt.args, out, tt.want)
+		}
+	}
+}
+
+func TestNumericCode(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	code, out := runCLI(t, "--url-template", cbr.template(), "--currency", "840,392", "--date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	if want := "14.10.2026\tUSD\t92.50\tRUB\t1\n14.10.2026\tJPY\t0.62\tRUB\t100\n"; out != want {
+		t.Errorf("--currency 840,392 output = %q, want %q", out, want)
+	}
+}
+
+func TestIDColumn(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	code, out := runCLI(t, "--url-template", cbr.template(), "--with-id", "--currency", "usd", "--date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	if want := "14.10.2026\tUSD\t92.50\tRUB\t1\tR01235\n"; out != want {
+		t.Errorf("--with-id output = %q, want %q", out, want)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	var cache = filepath.Join(t.TempDir(), "cache")
+
+	code, out := runCLI(t, "--cache-path", cache, "--dry-run", "--date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	if want := "https://www.cbr.ru/scripts/XML_daily.asp?date_req=14/10/2026\n"; out != want {
+		t.Errorf("--dry-run output = %q, want %q", out, want)
+	}
+
+	// a URL per date of a range, from the --url-template
+	code, out = runCLI(t, "--cache-path", cache, "--dry-run", "--url-template", "http://mirror.test/daily?d=%s", "--from-date", "13.10.2026", "--to-date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("range exit code = %d, want %d", code, exitOK)
+	}
+	if want := "http://mirror.test/daily?d=13/10/2026\nhttp://mirror.test/daily?d=14/10/2026\n"; out != want {
+		t.Errorf("--dry-run range output = %q, want %q", out, want)
+	}
+
+	if _, err := os.Stat(cache); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("--dry-run created the cache file: %v", err)
+	}
+}
+
+func TestTrimZeros(t *testing.T) {
+	var tests = []struct {
+		value     string
+		precision int
+		want      string
+		trimmed   string
+	}{
+		{value: "90", precision: 2, want: "90.00", trimmed: "90"},
+		{value: "90,5", precision: 2, want: "90.50", trimmed: "90.5"},
+		{value: "92,5012", precision: 2, want: "92.50", trimmed: "92.5"},
+		// the zeros are trimmed after the rounding to the precision
+		{value: "92,5012", precision: 4, want: "92.5012", trimmed: "92.5012"},
+		{value: "92,5012", precision: 1, want: "92.5", trimmed: "92.5"},
+		{value: "100,0012", precision: 2, want: "100.00", trimmed: "100"},
+		{value: "90", precision: 0, want: "90", trimmed: "90"},
+	}
+
+	for _, tt := range tests {
+		for _, trim := range []bool{false, true} {
+			var v = Valute{CharCode: "USD", Nominal: 1, Value: tt.value, Date: testDate, Base: rubCurrency}
+			row, err := v.getRow(rowOptions{precision: tt.precision, trimZeros: trim})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want = tt.want
+			if trim {
+				want = tt.trimmed
+			}
+			if row[2] != want {
+				t.Errorf("%s at precision %d, trim %v = %s, want %s", tt.value, tt.precision, trim, row[2], want)
+			}
+		}
+	}
+}
+
+func TestInverse(t *testing.T) {
+	var tests = []struct {
+		v         Valute
+		precision int
+		want      string
+	}{
+		{v: Valute{CharCode: "USD", Nominal: 1, Value: "92,5012"}, precision: 6, want: "0.010811"},
+		{v: Valute{CharCode: "USD", Nominal: 1, Value: "92,5012"}, precision: 2, want: "0.01"},
+		// the nominal units per published value
+		{v: Valute{CharCode: "JPY", Nominal: 100, Value: "62,3456"}, precision: 6, want: "1.603962"},
+		// a zero rate has no reciprocal
+		{v: Valute{CharCode: "ZER", Nominal: 1, Value: "0"}, precision: 6, want: ""},
+	}
+
+	for _, tt := range tests {
+		tt.v.Date, tt.v.Base = testDate, rubCurrency
+		row, err := tt.v.getRow(rowOptions{precision: tt.precision, withInverse: true})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.v.CharCode, err)
+		}
+		if got := row[len(row)-1]; got != tt.want {
+			t.Errorf("%s at precision %d: inverse = %q, want %q", tt.v.CharCode, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestDuplicateCodes(t *testing.T) {
+	var args = []string{"--xml-file", "testdata/duplicate.xml", "--currency", "usd,eur", "--date", "14.10.2026"}
+
+	// the last USD wins with a warning
+	var code int
+	var out string
+	var stderr = capture(t, &os.Stderr, func() { code, out = runCLI(t, args...) })
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	if want := "14.10.2026\tUSD\t95.00\tRUB\t1\n14.10.2026\tEUR\t100.12\tRUB\t1\n"; out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+	if want := "currency code 'USD' is published more than once on 14.10.2026: R01235, R01236, using R01236"; !strings.Contains(stderr, want) {
+		t.Errorf("stderr = %q, want it to contain %q", stderr, want)
+	}
+
+	// --strict fails naming both identifiers
+	stderr = capture(t, &os.Stderr, func() { code, out = runCLI(t, append(args, "--strict")...) })
+	if code == exitOK || out != "" {
+		t.Errorf("--strict: exit code = %d, output = %q, want a failure without rows", code, out)
+	}
+	if want := "R01235, R01236"; !strings.Contains(stderr, want) {
+		t.Errorf("--strict stderr = %q, want it to contain %q", stderr, want)
+	}
+}
+
+func TestLatestOnWeekend(t *testing.T) {
+	// on Sunday the latest rates are those published for Friday
+	var friday = testDate.AddDate(0, 0, 2)
+	clock(t, 4*24+10)
+	var cbr = newFakeCBR(t, testDate, friday)
+
+	code, out := runCLI(t, "--url-template", cbr.template(), "--currency", "usd", latestCommand)
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	if want := "16.10.2026\tUSD\t92.50\tRUB\t1\n"; out != want {
+		t.Errorf("output = %q, want the rate of Friday %q", out, want)
+	}
+	// Sunday and Saturday are empty, then Friday
+	if n := cbr.requests.Load(); n != 3 {
+		t.Errorf("requests = %d, want 3", n)
+	}
+
+	for _, args := range [][]string{{"--date", "14.10.2026"}, {"--no-fallback"}} {
+		if code, _ := runCLI(t, append(append([]string{"--url-template", cbr.template()}, args...), latestCommand)...); code != exitUsage {
+			t.Errorf("%v: exit code = %d, want %d", args, code, exitUsage)
+		}
+	}
+}
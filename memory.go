@@ -0,0 +1,168 @@
+package main
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dayRates are the rates loaded for a requested date.
+type dayRates struct {
+	// date the rates were actually published for
+	date time.Time
+	// rows and rates per unit by lower-case currency code
+	rows   map[string][]string
+	values map[string]float64
+	// lower-case currency codes by ISO 4217 numeric code
+	codes map[string]string
+	// published valutes by lower-case currency code
+	valutes map[string]*Valute
+	// lower-case currency codes in the published order
+	order []string
+	// where the rates were loaded from
+	origin origin
+}
+
+// code resolves a numeric currency code to the lower-case alphabetic one.
+func (d *dayRates) code(name string) string {
+	name = strings.ToLower(name)
+	if code, ok := d.codes[name]; ok {
+		return code
+	}
+	return name
+}
+
+// row returns the row of the currency by alphabetic or numeric code.
+func (d *dayRates) row(name string, t time.Time) ([]string, error) {
+	if row, ok := d.rows[d.code(name)]; ok {
+		return row, nil
+	}
+	return nil, &CurrencyNotFoundError{Code: name, Date: t}
+}
+
+// defaultMemCacheSize is the number of days kept in memory, enough for
+// the rate history of a few years loaded ahead of a date range.
+const defaultMemCacheSize = 1024
+
+// memoryRates keeps the most recently used days in memory by requested
+// date, in front of the disk cache. It is safe for concurrent use; the
+// stored days must not be modified.
+type memoryRates struct {
+	mu   sync.Mutex
+	size int
+	days map[string]*list.Element
+	lru  *list.List
+}
+
+type memoryEntry struct {
+	key string
+	day *dayRates
+	// loaded is when the rates of the day were fetched from the provider
+	loaded time.Time
+}
+
+func newMemoryRates(size int) *memoryRates {
+	return &memoryRates{size: size, days: map[string]*list.Element{}, lru: list.New()}
+}
+
+// get returns the day for t. A day expires as in the cache file, today's
+// rates and fallbacks fetched before t was over ttl after they were
+// fetched, and is dropped.
+func (m *memoryRates) get(t time.Time, ttl time.Duration) (d *dayRates, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.days[ratesKey(t)]
+	if !ok {
+		return nil, false
+	}
+
+	var entry = e.Value.(*memoryEntry)
+	if (cachedDay{Date: entry.day.date, CachedAt: entry.loaded}).expired(t, ttl) {
+		m.lru.Remove(e)
+		delete(m.days, entry.key)
+		return nil, false
+	}
+
+	m.lru.MoveToFront(e)
+	return entry.day, true
+}
+
+// set stores the day fetched at loaded, evicting the least recently used
+// one when full.
+func (m *memoryRates) set(t time.Time, d *dayRates, loaded time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var key = ratesKey(t)
+	if e, ok := m.days[key]; ok {
+		e.Value.(*memoryEntry).day = d
+		e.Value.(*memoryEntry).loaded = loaded
+		m.lru.MoveToFront(e)
+		return
+	}
+
+	m.days[key] = m.lru.PushFront(&memoryEntry{key: key, day: d, loaded: loaded})
+	if m.lru.Len() > m.size {
+		oldest := m.lru.Remove(m.lru.Back()).(*memoryEntry)
+		delete(m.days, oldest.key)
+	}
+}
+
+// reset drops every day.
+func (m *memoryRates) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.days = map[string]*list.Element{}
+	m.lru.Init()
+}
+
+// publishedRates keeps the days the emitted rows were built from by
+// published date. Unlike the days in memory they are never evicted, so
+// the rows can be traced back to their rates whatever the size of the
+// memory cache. It is safe for concurrent use.
+type publishedRates struct {
+	mu   sync.Mutex
+	days map[string]*dayRates
+}
+
+func newPublishedRates() *publishedRates {
+	return &publishedRates{days: map[string]*dayRates{}}
+}
+
+// add records the day a row was built from.
+func (p *publishedRates) add(d *dayRates) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.days[ratesKey(d.date)] = d
+}
+
+// value returns the rate per unit of the currency published for the date,
+// as formatted by ratesKey.
+func (p *publishedRates) value(code, date string) (float64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	day, ok := p.days[date]
+	if !ok {
+		return 0, false
+	}
+	val, ok := day.values[strings.ToLower(code)]
+	return val, ok
+}
+
+// valute returns the valute of the currency published for the date, as
+// formatted by ratesKey. It is nil when no row was built from it.
+func (p *publishedRates) valute(code, date string) *Valute {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	day, ok := p.days[date]
+	if !ok {
+		return nil
+	}
+	return day.valutes[strings.ToLower(code)]
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRatesKeyedByDate(t *testing.T) {
+	var yesterday = testDate.AddDate(0, 0, -1)
+	var cbr = newFakeCBR(t, yesterday, testDate)
+	var app = cbr.app(t)
+
+	for _, d := range []string{"14.10.2026", "13.10.2026", "14.10.2026"} {
+		date, err := parseDate(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		row, err := app.getCurrencyItemCache(context.Background(), "usd", date, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row[0] != d {
+			t.Errorf("rates for %s are of %s", d, row[0])
+		}
+	}
+
+	// the second date is fetched, the first one again comes from memory
+	if n := cbr.requests.Load(); n != 2 {
+		t.Errorf("requests = %d, want 2", n)
+	}
+}
+
+func TestMemoryRatesExpired(t *testing.T) {
+	var advance = clock(t, 8)
+	var yesterday = testDate.AddDate(0, 0, -1)
+
+	var mem = newMemoryRates(defaultMemCacheSize)
+	mem.set(testDate, &dayRates{date: testDate}, now())
+	mem.set(yesterday, &dayRates{date: yesterday}, now())
+
+	if _, ok := mem.get(testDate, 12*time.Hour); !ok {
+		t.Error("today's rates expired within the ttl")
+	}
+
+	// only today's rates expire, like in the cache file
+	advance(13 * time.Hour)
+	if _, ok := mem.get(testDate, 12*time.Hour); ok {
+		t.Error("today's rates kept past the ttl")
+	}
+	if _, ok := mem.get(yesterday, 12*time.Hour); !ok {
+		t.Error("past rates expired")
+	}
+
+	// an expired day is dropped
+	if _, ok := mem.get(testDate, 24*time.Hour); ok {
+		t.Error("expired rates kept in memory")
+	}
+}
+
+func TestMemoryRatesEviction(t *testing.T) {
+	clock(t, 8)
+	var mem = newMemoryRates(2)
+	var days = []time.Time{testDate.AddDate(0, 0, -2), testDate.AddDate(0, 0, -1), testDate}
+
+	mem.set(days[0], &dayRates{date: days[0]}, now())
+	mem.set(days[1], &dayRates{date: days[1]}, now())
+
+	// reading the first day makes the second one the least recently used
+	if _, ok := mem.get(days[0], defaultCacheTTL); !ok {
+		t.Fatal("first day missing")
+	}
+	mem.set(days[2], &dayRates{date: days[2]}, now())
+
+	for i, want := range []bool{true, false, true} {
+		if _, ok := mem.get(days[i], defaultCacheTTL); ok != want {
+			t.Errorf("%s in memory = %v, want %v", ratesKey(days[i]), ok, want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	cacheHit  = "hit"
+	cacheMiss = "miss"
+)
+
+var (
+	providerRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "currency_provider_requests_total",
+		Help: "HTTP requests made to the rates provider.",
+	}, []string{"provider"})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "currency_cache_lookups_total",
+		Help: "Rate lookups by cache result.",
+	}, []string{"result"})
+
+	requestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "currency_request_errors_total",
+		Help: "Failed rate requests by error type.",
+	}, []string{"type"})
+)
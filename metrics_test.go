@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// scrape returns the samples of the /metrics endpoint by metric and labels.
+func scrape(t *testing.T, h http.Handler) map[string]float64 {
+	t.Helper()
+	var rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/metrics status = %d", rec.Code)
+	}
+
+	var samples = map[string]float64{}
+	var scanner = bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var line = scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		i := strings.LastIndexByte(line, ' ')
+		val, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("malformed sample %q", line)
+		}
+		samples[line[:i]] = val
+	}
+	return samples
+}
+
+func TestMetrics(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	var mux = cbr.app(t).newServeMux()
+	var before = scrape(t, mux)
+	for _, code := range []string{"usd", "eur", "xyz"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/rate?date=14.10.2026&currency="+code, nil))
+	}
+	var after = scrape(t, mux)
+
+	var want = map[string]float64{
+		`currency_provider_requests_total{provider="cbr"}`: 1,
+		`currency_cache_lookups_total{result="miss"}`:      1,
+		`currency_cache_lookups_total{result="hit"}`:       2,
+		`currency_request_errors_total{type="not_found"}`:  1,
+	}
+	for sample, delta := range want {
+		if got := after[sample] - before[sample]; got != delta {
+			t.Errorf("%s grew by %v, want %v", sample, got, delta)
+		}
+	}
+}
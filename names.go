@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+)
+
+// nameLanguages are the languages of the currency names. Russian names are
+// the ones published by CBR; x/text has currency symbols but no display
+// names, so the other languages are bundled.
+var nameLanguages = []language.Tag{language.Russian, language.English}
+
+var nameMatcher = language.NewMatcher(nameLanguages)
+
+var localizedNames = map[language.Tag]map[string]string{
+	language.English: {
+		"AED": "UAE Dirham",
+		"AMD": "Armenian Dram",
+		"AUD": "Australian Dollar",
+		"AZN": "Azerbaijani Manat",
+		"BGN": "Bulgarian Lev",
+		"BHD": "Bahraini Dinar",
+		"BOB": "Bolivian Boliviano",
+		"BRL": "Brazilian Real",
+		"BYN": "Belarusian Ruble",
+		"CAD": "Canadian Dollar",
+		"CHF": "Swiss Franc",
+		"CNY": "Chinese Yuan",
+		"CUP": "Cuban Peso",
+		"CZK": "Czech Koruna",
+		"DKK": "Danish Krone",
+		"DZD": "Algerian Dinar",
+		"EGP": "Egyptian Pound",
+		"ETB": "Ethiopian Birr",
+		"EUR": "Euro",
+		"GBP": "British Pound",
+		"GEL": "Georgian Lari",
+		"HKD": "Hong Kong Dollar",
+		"HUF": "Hungarian Forint",
+		"IDR": "Indonesian Rupiah",
+		"INR": "Indian Rupee",
+		"IRR": "Iranian Rial",
+		"JPY": "Japanese Yen",
+		"KGS": "Kyrgystani Som",
+		"KRW": "South Korean Won",
+		"KZT": "Kazakhstani Tenge",
+		"MDL": "Moldovan Leu",
+		"MMK": "Myanmar Kyat",
+		"MNT": "Mongolian Tugrik",
+		"NGN": "Nigerian Naira",
+		"NOK": "Norwegian Krone",
+		"NZD": "New Zealand Dollar",
+		"OMR": "Omani Rial",
+		"PLN": "Polish Zloty",
+		"QAR": "Qatari Riyal",
+		"RON": "Romanian Leu",
+		"RSD": "Serbian Dinar",
+		"RUB": "Russian Ruble",
+		"SAR": "Saudi Riyal",
+		"SEK": "Swedish Krona",
+		"SGD": "Singapore Dollar",
+		"THB": "Thai Baht",
+		"TJS": "Tajikistani Somoni",
+		"TMT": "Turkmenistani Manat",
+		"TRY": "Turkish Lira",
+		"UAH": "Ukrainian Hryvnia",
+		"USD": "US Dollar",
+		"UZS": "Uzbekistani Som",
+		"VND": "Vietnamese Dong",
+		"XDR": "Special Drawing Rights",
+		"ZAR": "South African Rand",
+	},
+}
+
+// resolveLanguage picks the supported language of the currency names
+// closest to the BCP 47 tag lang. Without a close one the names published
+// by CBR, in Russian, are used.
+func resolveLanguage(lang string) (tag language.Tag, err error) {
+	requested, err := language.Parse(lang)
+	if err != nil {
+		return tag, fmt.Errorf("invalid language '%s': %w", lang, err)
+	}
+
+	_, i, confidence := nameMatcher.Match(requested)
+	if confidence == language.No {
+		logger.with("lang", lang).Debugf("no currency names in %s, using the published ones", lang)
+		return language.Russian, nil
+	}
+
+	return nameLanguages[i], nil
+}
+
+// localizedName returns the name of the currency in the language lang,
+// the published name when lang is not set or there is no translation for
+// the code.
+func localizedName(code, published string, lang language.Tag) string {
+	if lang == language.Und || lang == language.Russian {
+		return published
+	}
+
+	unit, err := currency.ParseISO(code)
+	if err != nil {
+		return published
+	}
+
+	if name, ok := localizedNames[lang][unit.String()]; ok {
+		return name
+	}
+	return published
+}
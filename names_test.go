@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestResolveLanguage(t *testing.T) {
+	var tests = []struct {
+		lang string
+		want language.Tag
+	}{
+		{lang: "ru", want: language.Russian},
+		{lang: "en", want: language.English},
+		{lang: "en-GB", want: language.English},
+		{lang: "ru-RU", want: language.Russian},
+		// no bundled names, the published ones are used
+		{lang: "de", want: language.Russian},
+		{lang: "zh-Hant", want: language.Russian},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveLanguage(tt.lang)
+		if err != nil {
+			t.Errorf("%s: %v", tt.lang, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: language = %v, want %v", tt.lang, got, tt.want)
+		}
+	}
+
+	if _, err := resolveLanguage("not a tag!"); err == nil {
+		t.Error("invalid language tag accepted")
+	}
+}
+
+func TestLocalizedName(t *testing.T) {
+	var tests = []struct {
+		code string
+		lang language.Tag
+		want string
+	}{
+		{code: "USD", lang: language.English, want: "US Dollar"},
+		{code: "USD", lang: language.Russian, want: "Доллар США"},
+		{code: "USD", lang: language.Und, want: "Доллар США"},
+		// no translation of the code
+		{code: "XAU", lang: language.English, want: "Доллар США"},
+	}
+
+	for _, tt := range tests {
+		if got := localizedName(tt.code, "Доллар США", tt.lang); got != tt.want {
+			t.Errorf("%s in %v = %q, want %q", tt.code, tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestLangFlag(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	for lang, want := range map[string]string{
+		"en": "14.10.2026\tUSD\t92.50\tRUB\t1\tUS Dollar\n",
+		"de": "14.10.2026\tUSD\t92.50\tRUB\t1\tДоллар США\n",
+	} {
+		code, out := runCLI(t, "--url-template", cbr.template(), "--lang", lang, "--with-name", "--currency", "usd", "--date", "14.10.2026")
+		if code != exitOK {
+			t.Errorf("--lang %s: exit code = %d, want %d", lang, code, exitOK)
+		}
+		if out != want {
+			t.Errorf("--lang %s output = %q, want %q", lang, out, want)
+		}
+	}
+}
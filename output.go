@@ -0,0 +1,493 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"currency/currency"
+)
+
+const (
+	tsvFormat      = "tsv"
+	csvFormat      = "csv"
+	jsonFormat     = "json"
+	ndjsonFormat   = "ndjson"
+	markdownFormat = "markdown"
+	xmlFormat      = "xml"
+)
+
+var (
+	outputFormats = []string{tsvFormat, csvFormat, jsonFormat, ndjsonFormat, markdownFormat, xmlFormat}
+
+	// numericColumns are right-aligned in Markdown tables and are numbers
+	// in JSON
+	numericColumns = map[string]bool{
+		"rate":           true,
+		"from_rate":      true,
+		"to_rate":        true,
+		"nominal":        true,
+		"entries":        true,
+		"absent":         true,
+		"bytes":          true,
+		"amount":         true,
+		"value":          true,
+		"inverse":        true,
+		"change":         true,
+		"change_percent": true,
+	}
+
+	// dateColumns are ISO 8601 dates in JSON
+	dateColumns = map[string]bool{
+		"date":           true,
+		"from_date":      true,
+		"to_date":        true,
+		"requested_date": true,
+		"first_date":     true,
+		"last_date":      true,
+	}
+)
+
+const jsonDateFormat = "2006-01-02"
+
+// isNumericColumn reports whether the column holds numbers, the named
+// numeric columns or the dates of a pivot table.
+func isNumericColumn(name string) bool {
+	if numericColumns[name] {
+		return true
+	}
+	_, err := time.Parse(jsonDateFormat, name)
+	return err == nil
+}
+
+// jsonObject is a JSON object which keeps its keys in the given order.
+// Numeric columns are encoded as numbers, or null when blank, and date
+// columns as ISO 8601 dates.
+type jsonObject struct {
+	keys   []string
+	values []string
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := jsonValue(key, o.values[i])
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func jsonValue(key, value string) ([]byte, error) {
+	if isNumericColumn(key) {
+		if value == "" {
+			return []byte("null"), nil
+		}
+
+		// NaN and Inf parse as floats but are not JSON numbers
+		_, err := strconv.ParseFloat(value, 64)
+		if err != nil || !json.Valid([]byte(value)) {
+			return nil, fmt.Errorf("invalid %s '%s'", key, value)
+		}
+		return []byte(value), nil
+	}
+
+	if dateColumns[key] {
+		t, err := time.Parse(outputDateFormat, value)
+		if err == nil {
+			value = t.Format(jsonDateFormat)
+		}
+	}
+
+	return json.Marshal(value)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// stdoutPath names stdout among the --output destinations.
+const stdoutPath = "-"
+
+// outputPaths collects the destinations of repeated --output flags.
+type outputPaths []string
+
+func (p *outputPaths) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *outputPaths) Set(path string) error {
+	*p = append(*p, path)
+	return nil
+}
+
+// multiOutput writes the results to several destinations and closes the
+// files among them.
+type multiOutput struct {
+	io.Writer
+	files []*os.File
+}
+
+func (m multiOutput) Close() error {
+	var errs []error
+	for _, f := range m.files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// openOutput returns the destination of the results: every file of paths,
+// truncated or appended to, and stdout for "-" or when paths is empty.
+func openOutput(paths []string, appendMode bool) (io.WriteCloser, error) {
+	if len(paths) == 0 {
+		paths = []string{stdoutPath}
+	}
+
+	var flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendMode {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	var out multiOutput
+	var writers []io.Writer
+	for _, path := range paths {
+		if path == stdoutPath || path == "" {
+			writers = append(writers, os.Stdout)
+			continue
+		}
+
+		f, err := os.OpenFile(path, flags, 0666)
+		if err != nil {
+			out.Close()
+			return nil, err
+		}
+		out.files = append(out.files, f)
+		writers = append(writers, f)
+	}
+
+	// a single destination is kept as is, stdout may be a terminal
+	switch {
+	case len(writers) == 1 && len(out.files) == 0:
+		return nopWriteCloser{os.Stdout}, nil
+	case len(writers) == 1:
+		return out.files[0], nil
+	}
+
+	out.Writer = io.MultiWriter(writers...)
+	return out, nil
+}
+
+func validateFormat(format string) error {
+	for _, f := range outputFormats {
+		if f == format {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown output format '%s', expected one of: %v", format, outputFormats)
+}
+
+// writeRows writes the rate rows, TSV change columns are colorized when
+// the options say so. The XML valutes get the numeric codes of the days
+// the rows were built from.
+func (a *App) writeRows(w io.Writer, format string, rows [][]string) error {
+	header, rows := a.opts.rows.selectFields(rows)
+	if a.opts.colorize && format == tsvFormat && a.opts.rows.withChange {
+		rows = colorizeChange(header, rows)
+	}
+
+	if format == xmlFormat {
+		return a.writeXML(w, header, rows, a.published.valute)
+	}
+	return a.writeTable(w, format, header, rows)
+}
+
+// writeTable writes rows in the given format. The header names the columns;
+// it is used as object keys for JSON and is not written for TSV and CSV.
+func (a *App) writeTable(w io.Writer, format string, header []string, rows [][]string) (err error) {
+	err = validateFormat(format)
+	if err != nil {
+		return
+	}
+
+	// JSON dates are always ISO 8601 and XML dates as published by CBR
+	if format != jsonFormat && format != ndjsonFormat && format != xmlFormat {
+		rows = formatDates(header, rows, a.opts.rows.dateFormat)
+	}
+
+	switch format {
+	case jsonFormat:
+		return writeJSON(w, header, rows, a.opts.prettyJSON)
+	case ndjsonFormat:
+		return writeNDJSON(w, header, rows)
+	case markdownFormat:
+		return writeMarkdown(w, header, rows)
+	case xmlFormat:
+		return a.writeXML(w, header, rows, nil)
+	case csvFormat:
+		return writeDelimited(w, ',', rows)
+	default:
+		return writeDelimited(w, '\t', rows)
+	}
+}
+
+func writeDelimited(w io.Writer, comma rune, rows [][]string) error {
+	var writer = csv.NewWriter(w)
+	writer.Comma = comma
+	return writer.WriteAll(rows)
+}
+
+// writeJSON writes the rows as an array of objects, indented when pretty
+// is set.
+func writeJSON(w io.Writer, header []string, rows [][]string, pretty bool) error {
+	var out = make([]jsonObject, 0, len(rows))
+	for _, row := range rows {
+		if len(row) != len(header) {
+			return fmt.Errorf("malformed row: %v", row)
+		}
+
+		out = append(out, jsonObject{keys: header, values: row})
+	}
+
+	var enc = json.NewEncoder(w)
+	if pretty {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(out)
+}
+
+// writeNDJSON writes a JSON object per row and line, flushing w after each
+// line when it buffers, so a consumer reads the rows as they come.
+func writeNDJSON(w io.Writer, header []string, rows [][]string) error {
+	var flusher, buffered = w.(interface{ Flush() error })
+	for _, row := range rows {
+		if len(row) != len(header) {
+			return fmt.Errorf("malformed row: %v", row)
+		}
+
+		line, err := json.Marshal(jsonObject{keys: header, values: row})
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(append(line, '\n'))
+		if err != nil {
+			return err
+		}
+
+		if buffered {
+			err = flusher.Flush()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// columnTitle turns a header name like change_percent into Change percent.
+func columnTitle(name string) string {
+	name = strings.Replace(name, "_", " ", -1)
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+func markdownCell(s string) string {
+	return strings.Replace(s, "|", "\\|", -1)
+}
+
+func writeMarkdown(w io.Writer, header []string, rows [][]string) error {
+	var lines = make([]string, 0, len(rows)+2)
+
+	var titles = make([]string, len(header))
+	var separators = make([]string, len(header))
+	for i, name := range header {
+		titles[i] = columnTitle(name)
+		separators[i] = ":---"
+		if isNumericColumn(name) {
+			separators[i] = "---:"
+		}
+	}
+	lines = append(lines, "| "+strings.Join(titles, " | ")+" |")
+	lines = append(lines, "| "+strings.Join(separators, " | ")+" |")
+
+	for _, row := range rows {
+		if len(row) != len(header) {
+			return fmt.Errorf("malformed row: %v", row)
+		}
+
+		var cells = make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = markdownCell(cell)
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+	}
+
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// xmlCurrencyMarket is the name attribute of the CBR daily rates.
+const xmlCurrencyMarket = "Foreign Currency Market"
+
+// xmlValueDecimals are the decimals of the values CBR publishes, the
+// cross rates of --base are rounded to them.
+const xmlValueDecimals = 4
+
+// xmlDocument holds the ValCurs of several dates, a single date is written
+// as a bare ValCurs like the CBR daily rates.
+type xmlDocument struct {
+	XMLName xml.Name            `xml:"Rates"`
+	Days    []*currency.ValCurs `xml:"ValCurs"`
+}
+
+// writeXML writes rate rows in the shape of the CBR daily rates, a ValCurs
+// per date in the order of the rows. It applies to rate rows only; published
+// looks up the valutes of the rows, which are written as published. The
+// valutes it does not know, or all when it is nil, are rebuilt from the
+// rows.
+func (a *App) writeXML(w io.Writer, header []string, rows [][]string, published func(code, date string) *Valute) error {
+	var columns = map[string]int{}
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{"date", "code", "rate", "nominal"} {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("the %s format applies to rate rows only", xmlFormat)
+		}
+	}
+
+	var days []*currency.ValCurs
+	var byDate = map[string]*currency.ValCurs{}
+	for _, row := range rows {
+		if len(row) != len(header) {
+			return fmt.Errorf("malformed row: %v", row)
+		}
+
+		var date = row[columns["date"]]
+		day, ok := byDate[date]
+		if !ok {
+			day = &currency.ValCurs{Date: date, Name: xmlCurrencyMarket}
+			byDate[date] = day
+			days = append(days, day)
+		}
+
+		val, err := xmlValute(a.opts.rows, columns, row, published)
+		if err != nil {
+			return err
+		}
+		day.Valutes = append(day.Valutes, val)
+	}
+
+	var doc interface{} = xmlDocument{Days: days}
+	if len(days) == 1 {
+		doc = days[0]
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	err = xml.NewEncoder(w).Encode(doc)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// xmlValute returns the valute of the row. A published valute keeps its
+// ID, numeric code, name and value, quoted against the --base currency
+// when it is set; without it the Value is the rate column times the
+// nominal, --raw keeps the published values.
+func xmlValute(o rowOptions, columns map[string]int, row []string, published func(code, date string) *Valute) (*currency.Valute, error) {
+	var cell = func(name string) string {
+		if i, ok := columns[name]; ok {
+			return row[i]
+		}
+		return ""
+	}
+
+	if published != nil {
+		if v := published(cell("code"), cell("date")); v != nil {
+			return publishedValute(v, cell("code"))
+		}
+	}
+
+	nominal, err := strconv.ParseInt(cell("nominal"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nominal '%s' for %s", cell("nominal"), cell("code"))
+	}
+
+	var value = cell("rate")
+	if !o.raw {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate '%s' for %s", value, cell("code"))
+		}
+		value = o.format(rate * float64(nominal))
+	}
+
+	return &currency.Valute{
+		ID:       cell("id"),
+		CharCode: cell("code"),
+		Nominal:  nominal,
+		Name:     cell("name"),
+		Value:    strings.Replace(value, ".", ",", 1),
+	}, nil
+}
+
+// publishedValute returns the valute as published, with the emitted code.
+// A cross rate against the --base currency has the decimals of CBR.
+func publishedValute(v *Valute, code string) (*currency.Valute, error) {
+	var value = strings.TrimSpace(v.Value)
+	if v.baseValue != 0 {
+		val, err := v.getRawValue()
+		if err != nil {
+			return nil, err
+		}
+		value = strconv.FormatFloat(val/v.baseValue, 'f', xmlValueDecimals, 64)
+	}
+
+	return &currency.Valute{
+		ID:       v.ID,
+		NumCode:  v.NumCode,
+		CharCode: code,
+		Nominal:  v.Nominal,
+		Name:     v.Name,
+		Value:    strings.Replace(value, ".", ",", 1),
+	}, nil
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"currency/currency"
+)
+
+var testRows = [][]string{
+	{"14.10.2026", "USD", "92.50", "RUB", "1"},
+	{"14.10.2026", "JPY", "0.62", "RUB", "100"},
+}
+
+func TestWriteRows(t *testing.T) {
+	var tests = []struct {
+		format string
+		want   string
+		err    string
+	}{
+		{
+			format: tsvFormat,
+			want:   "14.10.2026\tUSD\t92.50\tRUB\t1\n14.10.2026\tJPY\t0.62\tRUB\t100\n",
+		},
+		{
+			format: csvFormat,
+			want:   "14.10.2026,USD,92.50,RUB,1\n14.10.2026,JPY,0.62,RUB,100\n",
+		},
+		{
+			format: jsonFormat,
+			want: `[{"date":"2026-10-14","code":"USD","rate":92.50,"base":"RUB","nominal":1},` +
+				`{"date":"2026-10-14","code":"JPY","rate":0.62,"base":"RUB","nominal":100}]` + "\n",
+		},
+		{
+			format: markdownFormat,
+			want: "| Date | Code | Rate | Base | Nominal |\n" +
+				"| :--- | :--- | ---: | :--- | ---: |\n" +
+				"| 14.10.2026 | USD | 92.50 | RUB | 1 |\n" +
+				"| 14.10.2026 | JPY | 0.62 | RUB | 100 |\n",
+		},
+		{
+			format: "yaml",
+			err:    "unknown output format 'yaml'",
+		},
+	}
+
+	var app = &App{mem: newMemoryRates(1)}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := app.writeRows(&buf, tt.format, testRows)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("writeRows(%s) error = %v, want %q", tt.format, err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("writeRows(%s): %v", tt.format, err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("writeRows(%s) = %q, want %q", tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteXML(t *testing.T) {
+	var app = newTestApp(t)
+	app.opts.provider = testProvider
+
+	rows, err := app.collectRows(context.Background(), []time.Time{testDate}, []string{"usd", "jpy"}, false, false, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = app.writeRows(&buf, xmlFormat, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the valutes are written as published, not rebuilt from the rounded
+	// rates
+	var got currency.ValCurs
+	err = xml.Unmarshal(buf.Bytes(), &got)
+	if err != nil {
+		t.Fatalf("decode %s: %v", buf.String(), err)
+	}
+	if got.Date != "14.10.2026" || got.Name != xmlCurrencyMarket || len(got.Valutes) != 2 {
+		t.Fatalf("document = %s", buf.String())
+	}
+
+	var want = []currency.Valute{
+		{ID: "R01235", NumCode: 840, CharCode: "USD", Nominal: 1, Name: "Доллар США", Value: "92,5012"},
+		{ID: "R01820", NumCode: 392, CharCode: "JPY", Nominal: 100, Name: "Японских иен", Value: "62,3456"},
+	}
+	for i, v := range got.Valutes {
+		v.XMLName = xml.Name{}
+		if *v != want[i] {
+			t.Errorf("valute %d = %+v, want %+v", i, *v, want[i])
+		}
+	}
+
+	// cross rates have the four decimals of CBR, 92.5012 / 100.1234
+	app = newTestApp(t)
+	app.opts.provider = testProvider
+	app.opts.base = eurCurrency
+	rows, err = app.collectRows(context.Background(), []time.Time{testDate}, []string{"usd"}, false, false, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	err = app.writeRows(&buf, xmlFormat, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); !strings.Contains(s, "<Value>0,9239</Value>") {
+		t.Errorf("cross rate document = %s", s)
+	}
+
+	// rows of no published valute are rebuilt without empty ID and Name
+	buf.Reset()
+	err = app.writeTable(&buf, xmlFormat, app.opts.rows.header(), testRows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); strings.Contains(s, "ID=") || strings.Contains(s, "<Name>") || !strings.Contains(s, "<Value>62,00</Value>") {
+		t.Errorf("rebuilt document = %s", s)
+	}
+}
+
+func TestOpenOutput(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "rates.tsv")
+	if err := os.WriteFile(path, []byte("previous results, longer than the new ones\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := openOutput([]string{path}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&App{mem: newMemoryRates(1)}).writeRows(out, tsvFormat, testRows); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "14.10.2026\tUSD\t92.50\tRUB\t1\n14.10.2026\tJPY\t0.62\tRUB\t100\n"; string(data) != want {
+		t.Errorf("file = %q, want %q", data, want)
+	}
+}
+
+func TestOutputFlag(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	code, out := runCLI(t, "--url-template", cbr.template(), "--currency", "usd", "--date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	if want := "14.10.2026\tUSD\t92.50\tRUB\t1\n"; out != want {
+		t.Errorf("--output file = %q, want %q", out, want)
+	}
+
+	var dir = filepath.Join(t.TempDir(), "missing")
+	if code, _ := runCLI(t, "--output", filepath.Join(dir, "out"), "--url-template", cbr.template(), "--currency", "usd", "--date", "14.10.2026"); code == exitOK {
+		t.Errorf("exit code for an unwritable --output = %d, want non-zero", code)
+	}
+}
+
+func TestWriteMarkdownColumns(t *testing.T) {
+	var header = []string{"date", "code", "rate", "base", "nominal", "name", "change", "change_percent"}
+	var rows = [][]string{
+		{"14.10.2026", "USD", "92.50", "RUB", "1", "Доллар США", "0.25", "0.27"},
+		{"14.10.2026", "XXX", "1.00", "RUB", "1", "a|b", "-0.01", "-1.00"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeMarkdown(&buf, header, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	var want = "| Date | Code | Rate | Base | Nominal | Name | Change | Change percent |\n" +
+		"| :--- | :--- | ---: | :--- | ---: | :--- | ---: | ---: |\n" +
+		"| 14.10.2026 | USD | 92.50 | RUB | 1 | Доллар США | 0.25 | 0.27 |\n" +
+		"| 14.10.2026 | XXX | 1.00 | RUB | 1 | a\\|b | -0.01 | -1.00 |\n"
+	if got := buf.String(); got != want {
+		t.Errorf("writeMarkdown() =\n%s\nwant\n%s", got, want)
+	}
+
+	if err := writeMarkdown(&buf, header, [][]string{{"14.10.2026"}}); err == nil {
+		t.Error("writeMarkdown() with a short row = nil, want an error")
+	}
+}
+
+func TestJSONTypes(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	code, out := runCLI(t, "--url-template", cbr.template(), "--format", "json", "--currency", "usd,jpy", "--date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+
+	// the rates and nominals are JSON numbers, the dates ISO 8601
+	var rates []struct {
+		Date    string  `json:"date"`
+		Code    string  `json:"code"`
+		Rate    float64 `json:"rate"`
+		Base    string  `json:"base"`
+		Nominal int64   `json:"nominal"`
+	}
+	err := json.Unmarshal([]byte(out), &rates)
+	if err != nil {
+		t.Fatalf("decode %s: %v", out, err)
+	}
+	if len(rates) != 2 {
+		t.Fatalf("decoded %d rates, want 2", len(rates))
+	}
+
+	for i, want := range []struct {
+		code    string
+		rate    float64
+		nominal int64
+	}{{code: "USD", rate: 92.5, nominal: 1}, {code: "JPY", rate: 0.62, nominal: 100}} {
+		var r = rates[i]
+		if r.Code != want.code || r.Rate != want.rate || r.Nominal != want.nominal || r.Base != "RUB" {
+			t.Errorf("rate %d = %+v, want %s %v per %d", i, r, want.code, want.rate, want.nominal)
+		}
+
+		date, err := time.Parse(jsonDateFormat, r.Date)
+		if err != nil || date.Format(outputDateFormat) != "14.10.2026" {
+			t.Errorf("rate %d date = %q, want 2026-10-14", i, r.Date)
+		}
+	}
+}
+
+func TestJSONPretty(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+	var args = []string{"--url-template", cbr.template(), "--format", "json", "--currency", "usd,jpy", "--date", "14.10.2026"}
+
+	code, compact := runCLI(t, args...)
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	code, pretty := runCLI(t, append(args, "--json-pretty")...)
+	if code != exitOK {
+		t.Fatalf("--json-pretty exit code = %d, want %d", code, exitOK)
+	}
+
+	var want = `[{"date":"2026-10-14","code":"USD","rate":92.50,"base":"RUB","nominal":1},{"date":"2026-10-14","code":"JPY","rate":0.62,"base":"RUB","nominal":100}]` + "\n"
+	if compact != want {
+		t.Errorf("compact output = %q, want %q", compact, want)
+	}
+
+	// the same document indented by two spaces
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(compact), "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	if pretty != indented.String() {
+		t.Errorf("--json-pretty output = %q, want %q", pretty, indented.String())
+	}
+	if !strings.HasPrefix(pretty, "[\n  {\n    \"date\": \"2026-10-14\",\n") {
+		t.Errorf("--json-pretty output = %q, want two-space indentation", pretty)
+	}
+
+	if code, _ := runCLI(t, "--url-template", cbr.template(), "--format", "csv", "--json-pretty", "--date", "14.10.2026"); code != exitUsage {
+		t.Errorf("--json-pretty with csv exit code = %d, want %d", code, exitUsage)
+	}
+}
+
+func TestMultipleOutputs(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+	var dir = t.TempDir()
+	var first, second = filepath.Join(dir, "first"), filepath.Join(dir, "second")
+	var args = []string{"--output", first, "--output", "-", "--output", second, "--url-template", cbr.template(), "--currency", "usd,jpy", "--date", "14.10.2026"}
+
+	var want = "14.10.2026\tUSD\t92.50\tRUB\t1\n14.10.2026\tJPY\t0.62\tRUB\t100\n"
+	for i, tt := range []struct {
+		args []string
+		file string
+	}{
+		{file: want},
+		// the files are truncated unless --append
+		{file: want},
+		{args: []string{"--append"}, file: want + want},
+	} {
+		var code int
+		var out string
+		var stdout = capture(t, &os.Stdout, func() { code, out = runCLI(t, append(args, tt.args...)...) })
+		if code != exitOK {
+			t.Fatalf("run %d: exit code = %d, want %d", i, code, exitOK)
+		}
+
+		// the --output file of runCLI is created by every run
+		if out != want || stdout != want {
+			t.Errorf("run %d: output = %q and stdout = %q, want %q", i, out, stdout, want)
+		}
+		for _, path := range []string{first, second} {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.file {
+				t.Errorf("run %d: %s = %q, want %q", i, filepath.Base(path), data, tt.file)
+			}
+		}
+	}
+}
+
+// flushRecorder records the lines written before each flush.
+type flushRecorder struct {
+	bytes.Buffer
+	flushed []string
+}
+
+func (r *flushRecorder) Flush() error {
+	r.flushed = append(r.flushed, r.String())
+	return nil
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	var header = []string{"date", "code", "rate", "base", "nominal", "name"}
+	var rows = [][]string{
+		{"14.10.2026", "USD", "92.50", "RUB", "1", "Доллар США"},
+		{"14.10.2026", "JPY", "0.62", "RUB", "100", "Line\nbreak"},
+	}
+
+	var w flushRecorder
+	err := writeNDJSON(&w, header, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a line per row, each a standalone object, flushed as it is written
+	var lines = strings.SplitAfter(w.String(), "\n")
+	if lines[len(lines)-1] != "" || len(lines) != len(rows)+1 {
+		t.Fatalf("output = %q, want %d lines", w.String(), len(rows))
+	}
+	for i, row := range rows {
+		var object map[string]interface{}
+		err := json.Unmarshal([]byte(lines[i]), &object)
+		if err != nil {
+			t.Errorf("line %d %q: %v", i, lines[i], err)
+			continue
+		}
+		if len(object) != len(header) || object["code"] != row[1] || object["name"] != row[5] {
+			t.Errorf("line %d = %v, want the object of %v", i, object, row)
+		}
+		if want := strings.Join(lines[:i+1], ""); i >= len(w.flushed) || w.flushed[i] != want {
+			t.Errorf("line %d was not flushed on its own", i)
+		}
+	}
+
+	if err := writeNDJSON(&w, header, [][]string{{"14.10.2026"}}); err == nil {
+		t.Error("writeNDJSON() with a short row = nil, want an error")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"time"
+)
+
+// pivotRows reshapes the rows of a date range into one row per currency,
+// in the order the currencies first appear, with the rates of the dates as
+// columns named by their ISO 8601 date. Dates without a published rate
+// are blank.
+func pivotRows(dates []time.Time, rows [][]string) (header []string, pivoted [][]string) {
+	var columns = map[string]int{}
+	header = []string{"code"}
+	for _, d := range dates {
+		if _, ok := columns[d.Format(outputDateFormat)]; ok {
+			continue
+		}
+		columns[d.Format(outputDateFormat)] = len(header)
+		header = append(header, d.Format(jsonDateFormat))
+	}
+
+	var byCode = map[string][]string{}
+	for _, row := range rows {
+		date, code, rate := row[0], row[1], row[2]
+		col, ok := columns[date]
+		if !ok {
+			continue
+		}
+
+		if _, ok := byCode[code]; !ok {
+			byCode[code] = make([]string, len(header))
+			byCode[code][0] = code
+			pivoted = append(pivoted, byCode[code])
+		}
+		byCode[code][col] = rate
+	}
+
+	return
+}
+
+// writePivot writes a pivoted table. Unlike the flat rows, TSV and CSV
+// start with the header, the dates are not known otherwise.
+func (a *App) writePivot(w io.Writer, format string, header []string, rows [][]string) error {
+	if format == tsvFormat || format == csvFormat {
+		rows = append([][]string{header}, rows...)
+	}
+	return a.writeTable(w, format, header, rows)
+}
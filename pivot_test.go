@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPivot(t *testing.T) {
+	// no rates are published on the 13th
+	var cbr = newFakeCBR(t, testDate.AddDate(0, 0, -2), testDate)
+	var args = []string{"--url-template", cbr.template(), "--pivot", "--currency", "usd,jpy", "--from-date", "12.10.2026", "--to-date", "14.10.2026"}
+
+	code, out := runCLI(t, args...)
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	var want = "code\t2026-10-12\t2026-10-13\t2026-10-14\n" +
+		"USD\t92.50\t\t92.50\n" +
+		"JPY\t0.62\t\t0.62\n"
+	if out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+
+	code, out = runCLI(t, append(args, "--format", "json")...)
+	if code != exitOK {
+		t.Fatalf("json exit code = %d, want %d", code, exitOK)
+	}
+	var got []map[string]interface{}
+	err := json.Unmarshal([]byte(out), &got)
+	if err != nil {
+		t.Fatalf("decode %s: %v", out, err)
+	}
+	// the blank cells are null
+	var wantJSON = []map[string]interface{}{
+		{"code": "USD", "2026-10-12": 92.5, "2026-10-13": nil, "2026-10-14": 92.5},
+		{"code": "JPY", "2026-10-12": 0.62, "2026-10-13": nil, "2026-10-14": 0.62},
+	}
+	if !reflect.DeepEqual(got, wantJSON) {
+		t.Errorf("json = %v, want %v", got, wantJSON)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"currency/currency"
+)
+
+const (
+	cbrProviderName     = "cbr"
+	cbrJSONProviderName = "cbr-json"
+	ecbProviderName     = "ecb"
+)
+
+// Provider is a source of daily exchange rates.
+type Provider interface {
+	// Name identifies the provider in flags and cache keys.
+	Name() string
+	// Base is the lower-case code of the currency the rates are quoted in.
+	Base() string
+	// URL is the address queried with the client for the rates of t.
+	URL(client *currency.Client, t time.Time) string
+	// Rates returns the valutes published for t, or with the fallback for
+	// the nearest day before it, the date they were actually published for
+	// and the validators of the response, requested with the client. The
+	// request is conditional on cond when it is for the same URL,
+	// currency.ErrNotModified means the rates of cond are current.
+	Rates(ctx context.Context, client *currency.Client, t time.Time, fallback bool, cond currency.Validators) (valutes []*Valute, date time.Time, v currency.Validators, err error)
+}
+
+var providers = map[string]Provider{
+	cbrProviderName:     cbrProvider{},
+	cbrJSONProviderName: cbrJSONProvider{},
+	ecbProviderName:     ecbProvider{},
+}
+
+// fetchRates queries the provider unless the network is disabled by the
+// offline mode.
+func (a *App) fetchRates(ctx context.Context, t time.Time, cond currency.Validators) (valutes []*Valute, date time.Time, v currency.Validators, err error) {
+	if a.opts.offline {
+		err = &NotCachedError{Date: t}
+		return
+	}
+
+	return a.opts.provider.Rates(ctx, a.client, t, a.opts.fallback, cond)
+}
+
+func getProvider(name string) (Provider, error) {
+	p, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider '%s', expected cbr, cbr-json or ecb", name)
+	}
+	return p, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	// roundHalfEven rounds the binary value of the rate to the nearest
+	// decimal, exact ties to the even digit. Most rates are not exactly
+	// representable, so 2.675, stored as 2.67499..., becomes 2.67.
+	roundHalfEven = "half-even"
+	// roundHalfUp rounds the shortest decimal form of the rate, ties away
+	// from zero: 2.675 becomes 2.68, 0.125 becomes 0.13 where half-even
+	// gives 0.12, and -2.675 becomes -2.68.
+	roundHalfUp = "half-up"
+)
+
+func validateRoundMode(mode string) error {
+	if mode != roundHalfEven && mode != roundHalfUp {
+		return fmt.Errorf("unknown round mode '%s', expected %s or %s", mode, roundHalfEven, roundHalfUp)
+	}
+	return nil
+}
+
+// roundUp formats val with precision decimal places rounding half up.
+func roundUp(val float64, precision int) string {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(val, 'f', -1, 64))
+	if !ok {
+		// NaN and Inf
+		return strconv.FormatFloat(val, 'f', precision, 64)
+	}
+
+	// FloatString rounds halves away from zero
+	return r.FloatString(precision)
+}
+
+const maxSigFigs = 17
+
+func validateSigFigs(n int) error {
+	if n < 1 || n > maxSigFigs {
+		return fmt.Errorf("significant figures %d are out of range 1-%d", n, maxSigFigs)
+	}
+	return nil
+}
+
+// roundSigFigs formats val in fixed notation with n significant digits,
+// rounding in the mode: 0.0012345 becomes 0.00123 and 12345 becomes 12300
+// with 3. A value rounded up to the next power of ten keeps n digits, 9.996
+// becomes 10.0.
+func roundSigFigs(val float64, n int, mode string) string {
+	if val == 0 || math.IsNaN(val) || math.IsInf(val, 0) {
+		return strconv.FormatFloat(val, 'f', n-1, 64)
+	}
+
+	var exp = decimalExponent(val)
+	var decimals = n - 1 - exp
+	if decimals >= 0 {
+		var s = roundDecimals(val, decimals, mode)
+		if r, err := strconv.ParseFloat(s, 64); err != nil || math.Abs(r) < math.Pow10(exp+1) {
+			return s
+		}
+		decimals--
+		if decimals >= 0 {
+			return roundDecimals(val, decimals, mode)
+		}
+	}
+
+	// above the significant digits the value is rounded to a multiple of
+	// a power of ten and padded with zeros
+	var zeros = strings.Repeat("0", -decimals)
+	if mode == roundHalfUp {
+		r, _ := new(big.Rat).SetString(strconv.FormatFloat(val, 'f', -1, 64))
+		r.Quo(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-decimals)), nil)))
+		return r.FloatString(0) + zeros
+	}
+
+	// the e format rounds the binary value half to even at its precision
+	// below the leading digit
+	var precision = exp + decimals
+	if precision < 0 {
+		precision = 0
+	}
+	return expandExponent(strconv.FormatFloat(val, 'e', precision, 64))
+}
+
+func roundDecimals(val float64, decimals int, mode string) string {
+	if mode == roundHalfUp {
+		return roundUp(val, decimals)
+	}
+	return strconv.FormatFloat(val, 'f', decimals, 64)
+}
+
+// decimalExponent is the power of ten of the leading digit of val.
+func decimalExponent(val float64) int {
+	var s = strconv.FormatFloat(math.Abs(val), 'e', -1, 64)
+	exp, _ := strconv.Atoi(s[strings.IndexByte(s, 'e')+1:])
+	return exp
+}
+
+// expandExponent turns the e format of an integer like 1.23e+04 into its
+// fixed notation 12300.
+func expandExponent(s string) string {
+	var i = strings.IndexByte(s, 'e')
+	exp, _ := strconv.Atoi(s[i+1:])
+	var digits = strings.Replace(s[:i], ".", "", 1)
+	var sign string
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	return sign + digits + strings.Repeat("0", exp-len(digits)+1)
+}
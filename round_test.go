@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRoundMode(t *testing.T) {
+	var tests = []struct {
+		value            string
+		halfEven, halfUp string
+	}{
+		// an exact tie goes to the even digit or up
+		{value: "0,125", halfEven: "0.12", halfUp: "0.13"},
+		{value: "0,375", halfEven: "0.38", halfUp: "0.38"},
+		// 2.675 is stored as 2.67499..., half-up rounds the decimal form
+		{value: "2,675", halfEven: "2.67", halfUp: "2.68"},
+		{value: "92,5012", halfEven: "92.50", halfUp: "92.50"},
+	}
+
+	for _, tt := range tests {
+		for mode, want := range map[string]string{roundHalfEven: tt.halfEven, roundHalfUp: tt.halfUp} {
+			var v = Valute{CharCode: "USD", Nominal: 1, Value: tt.value, Date: testDate, Base: rubCurrency}
+			row, err := v.getRow(rowOptions{precision: 2, roundMode: mode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if row[2] != want {
+				t.Errorf("%s rounded %s = %s, want %s", tt.value, mode, row[2], want)
+			}
+		}
+	}
+
+	for _, mode := range []string{"", "half-down", "HALF-UP"} {
+		if err := validateRoundMode(mode); err == nil {
+			t.Errorf("round mode %q accepted", mode)
+		}
+	}
+}
+
+func TestRoundSigFigs(t *testing.T) {
+	var tests = []struct {
+		val  float64
+		n    int
+		mode string
+		want string
+	}{
+		{val: 0.00123, n: 3, want: "0.00123"},
+		{val: 0.0012345, n: 3, want: "0.00123"},
+		{val: 0.0012345, n: 1, want: "0.001"},
+		{val: 12345, n: 3, want: "12300"},
+		{val: 12345, n: 5, want: "12345"},
+		{val: 12345, n: 7, want: "12345.00"},
+		{val: 92.5012, n: 3, want: "92.5"},
+		// rounded up to the next power of ten with n digits
+		{val: 9.996, n: 3, want: "10.0"},
+		{val: 0.09996, n: 2, want: "0.10"},
+		{val: 99999, n: 2, want: "100000"},
+		{val: 0, n: 3, want: "0.00"},
+		// a tie of the decimal form
+		{val: 0.125, n: 2, mode: roundHalfEven, want: "0.12"},
+		{val: 0.125, n: 2, mode: roundHalfUp, want: "0.13"},
+	}
+
+	for _, tt := range tests {
+		var mode = tt.mode
+		if mode == "" {
+			mode = roundHalfEven
+		}
+		if got := roundSigFigs(tt.val, tt.n, mode); got != tt.want {
+			t.Errorf("roundSigFigs(%v, %d, %s) = %s, want %s", tt.val, tt.n, mode, got, tt.want)
+		}
+	}
+
+	for _, n := range []int{0, maxSigFigs + 1} {
+		if err := validateSigFigs(n); err == nil {
+			t.Errorf("%d significant figures accepted", n)
+		}
+	}
+
+	var cbr = newFakeCBR(t, testDate)
+	code, out := runCLI(t, "--url-template", cbr.template(), "--sig-figs", "3", "--currency", "usd,jpy", "--date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	if want := "14.10.2026\tUSD\t92.5\tRUB\t1\n14.10.2026\tJPY\t0.623\tRUB\t100\n"; out != want {
+		t.Errorf("--sig-figs 3 output = %q, want %q", out, want)
+	}
+	if code, _ := runCLI(t, "--url-template", cbr.template(), "--sig-figs", "3", "--precision", "4", "--date", "14.10.2026"); code != exitUsage {
+		t.Errorf("--sig-figs with --precision exit code = %d, want %d", code, exitUsage)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultConcurrency = 4
+
+// dateRows returns the rows of the currencies for the requested date t.
+// In a date range days without published rates are skipped, they resolve
+// to another date and every date is listed once. Unless failFast is set
+// the rows of the other currencies are returned along with the errors.
+func (a *App) dateRows(ctx context.Context, t time.Time, currencies []string, skipCache bool, isRange bool) (rows [][]string, err error) {
+	if a.opts.allCodes {
+		currencies, err = a.publishedCodes(ctx, t, skipCache)
+		if err != nil {
+			return
+		}
+	}
+
+	var errs []error
+	for _, curr := range currencies {
+		row, day, err := a.currencyRow(ctx, curr, t, skipCache)
+		if err == nil {
+			err = a.validateRate(curr, day)
+		}
+		if err != nil && budgetExceeded(ctx) {
+			errs = append(errs, err)
+			break
+		}
+		if err != nil && a.opts.failFast {
+			return nil, err
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if isRange && row[0] != t.Format(outputDateFormat) {
+			continue
+		}
+
+		a.published.add(day)
+		a.explainRate(curr, t, day)
+
+		if a.opts.rows.withChange {
+			row = a.appendChange(ctx, row, curr, t, skipCache)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, errors.Join(errs...)
+}
+
+// publishedCodes returns the codes of every currency published for t.
+func (a *App) publishedCodes(ctx context.Context, t time.Time, skipCache bool) ([]string, error) {
+	day, err := a.dayRatesCache(ctx, t, skipCache)
+	if err != nil {
+		return nil, err
+	}
+	return day.order, nil
+}
+
+// budgetExceeded reports whether the deadline of ctx, the --timeout-total
+// budget, has passed. The rows gathered until then are still returned.
+func budgetExceeded(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// collectRows fetches the dates with at most concurrency workers and
+// returns the rows in the order of dates and currencies.
+func (a *App) collectRows(ctx context.Context, dates []time.Time, currencies []string, skipCache bool, isRange bool, concurrency int) (rows [][]string, err error) {
+	var results = make([][][]string, len(dates))
+	var errs = make([]error, len(dates))
+	var jobs = make(chan int)
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results[j], errs[j] = a.dateRows(ctx, dates[j], currencies, skipCache, isRange)
+			}
+		}()
+	}
+
+	for i := range dates {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var failed []error
+	for i := range dates {
+		if errs[i] != nil && a.opts.failFast && !budgetExceeded(ctx) {
+			return nil, errs[i]
+		}
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+		}
+		rows = append(rows, results[i]...)
+	}
+
+	if len(failed) > 0 && budgetExceeded(ctx) {
+		return rows, fmt.Errorf("total timeout exceeded with %d row(s) gathered: %w", len(rows), ctx.Err())
+	}
+	return rows, errors.Join(failed...)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"currency/currency"
+)
+
+// rowKeys returns the date and the code of the rows.
+func rowKeys(rows [][]string) (keys []string) {
+	for _, row := range rows {
+		keys = append(keys, row[0]+" "+row[1])
+	}
+	return
+}
+
+func TestCollectRowsRange(t *testing.T) {
+	var first = testDate.AddDate(0, 0, -2)
+	var cbr = newFakeCBR(t, first, testDate)
+
+	var dates = []time.Time{first, first.AddDate(0, 0, 1), testDate}
+	rows, err := cbr.app(t).collectRows(context.Background(), dates, []string{"usd", "eur"}, false, true, defaultConcurrency)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 13.10 has no rates, it falls back to 12.10 and is skipped
+	var want = []string{"12.10.2026 USD", "12.10.2026 EUR", "14.10.2026 USD", "14.10.2026 EUR"}
+	if got := rowKeys(rows); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+}
+
+func TestCollectRowsConcurrent(t *testing.T) {
+	var dates []time.Time
+	for i := 9; i >= 0; i-- {
+		dates = append(dates, testDate.AddDate(0, 0, -i))
+	}
+	var cbr = newFakeCBR(t, dates...)
+
+	rows, err := cbr.app(t).collectRows(context.Background(), dates, []string{"usd", "jpy"}, false, true, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []string
+	for _, d := range dates {
+		want = append(want, ratesKey(d)+" USD", ratesKey(d)+" JPY")
+	}
+	if got := rowKeys(rows); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+	if n := cbr.requests.Load(); n != int32(len(dates)) {
+		t.Errorf("requests = %d, want one per date", n)
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	var tests = []struct {
+		failFast string
+		want     string
+	}{
+		{failFast: "true"},
+		// the rows of the valid currencies are still written
+		{failFast: "false", want: "14.10.2026\tUSD\t92.50\tRUB\t1\n14.10.2026\tJPY\t0.62\tRUB\t100\n"},
+	}
+
+	for _, tt := range tests {
+		code, out := runCLI(t, "--url-template", cbr.template(), "--fail-fast="+tt.failFast, "--currency", "usd,xyz,jpy", "--date", "14.10.2026")
+		if code != exitNotFound {
+			t.Errorf("--fail-fast=%s: exit code = %d, want %d", tt.failFast, code, exitNotFound)
+		}
+		if out != tt.want {
+			t.Errorf("--fail-fast=%s: output = %q, want %q", tt.failFast, out, tt.want)
+		}
+	}
+}
+
+func TestAllCurrencies(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	body, err := os.ReadFile("testdata/daily.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	valCurs, err := currency.Decode(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, out := runCLI(t, "--url-template", cbr.template(), "--all", "--sort", "code", "--date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+
+	var lines = strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != len(valCurs.Valutes) {
+		t.Fatalf("--all wrote %d rows, want one for each of the %d valutes", len(lines), len(valCurs.Valutes))
+	}
+	for i, code := range []string{"EUR", "JPY", "USD"} {
+		if fields := strings.Split(lines[i], "\t"); fields[1] != code {
+			t.Errorf("row %d code = %s, want %s", i, fields[1], code)
+		}
+	}
+	if n := cbr.requests.Load(); n != 1 {
+		t.Errorf("requests = %d, want 1 for the whole day", n)
+	}
+}
+
+func TestTimeoutTotal(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate.AddDate(0, 0, -2), testDate.AddDate(0, 0, -1), testDate)
+
+	// the last date hangs until the request is canceled
+	var slow = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("date_req") == testDate.Format(xmlDateFormat) {
+			<-r.Context().Done()
+			return
+		}
+		cbr.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer slow.Close()
+
+	var code int
+	var out string
+	var start = time.Now()
+	var stderr = capture(t, &os.Stderr, func() {
+		code, out = runCLI(t, "--url-template", slow.URL+"/?date_req=%s", "--timeout-total", "200ms", "--retries", "0",
+			"--currency", "usd", "--from-date", "12.10.2026", "--to-date", "14.10.2026")
+	})
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("run took %v, want it canceled by the 200ms budget", elapsed)
+	}
+	if code == exitOK {
+		t.Errorf("exit code = %d, want a failure", code)
+	}
+	if want := "12.10.2026\tUSD\t92.50\tRUB\t1\n13.10.2026\tUSD\t92.50\tRUB\t1\n"; out != want {
+		t.Errorf("output = %q, want the rows gathered before the deadline %q", out, want)
+	}
+	if !strings.Contains(stderr, "deadline exceeded") {
+		t.Errorf("stderr = %q, want a deadline error", stderr)
+	}
+}
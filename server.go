@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	defaultListen   = ":8080"
+	shutdownTimeout = 5 * time.Second
+)
+
+func (a *App) newServeMux() *http.ServeMux {
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/rate", a.rateHandler)
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", a.readyzHandler)
+	return mux
+}
+
+// rateHandler serves /rate?currency=usd&date=02.01.2006, the date defaults
+// to today.
+func (a *App) rateHandler(w http.ResponseWriter, r *http.Request) {
+	var code = a.opts.resolveAlias(r.URL.Query().Get("currency"))
+	if code == "" {
+		requestErrors.WithLabelValues("bad_request").Inc()
+		writeJSONError(w, http.StatusBadRequest, errors.New("currency is required"))
+		return
+	}
+
+	var t = now()
+	if date := r.URL.Query().Get("date"); date != "" {
+		var err error
+		t, err = parseDate(date)
+		if err != nil {
+			requestErrors.WithLabelValues("bad_request").Inc()
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	row, err := a.getCurrencyItemCache(r.Context(), code, t, false)
+	if err == nil && a.opts.rows.withChange {
+		row = a.appendChange(r.Context(), row, code, t, false)
+	}
+
+	var notFound *CurrencyNotFoundError
+	if errors.As(err, &notFound) {
+		requestErrors.WithLabelValues("not_found").Inc()
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err != nil {
+		requestErrors.WithLabelValues("upstream").Inc()
+		writeJSONError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	header, rows := a.opts.rows.selectFields([][]string{row})
+	writeJSONResponse(w, http.StatusOK, jsonObject{keys: header, values: rows[0]})
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		logger.Errorf("%v", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSONResponse(w, status, map[string]string{"error": err.Error()})
+}
+
+// serve runs the HTTP server until ctx is done and then shuts it
+// down gracefully.
+func (a *App) serve(ctx context.Context, addr string) error {
+	var srv = &http.Server{
+		Addr:    addr,
+		Handler: a.newServeMux(),
+	}
+
+	logger.Infof("listening on %s", addr)
+
+	var errs = make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return srv.Shutdown(shutdownCtx)
+}
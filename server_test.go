@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateHandler(t *testing.T) {
+	var tests = []struct {
+		query  string
+		status int
+		body   string
+	}{
+		{
+			query:  "currency=usd&date=14.10.2026",
+			status: http.StatusOK,
+			body:   `{"date":"2026-10-14","code":"USD","rate":92.50,"base":"RUB","nominal":1}`,
+		},
+		{
+			query:  "currency=978&date=14.10.2026",
+			status: http.StatusOK,
+			body:   `{"date":"2026-10-14","code":"EUR","rate":100.12,"base":"RUB","nominal":1}`,
+		},
+		{query: "date=14.10.2026", status: http.StatusBadRequest, body: `{"error":"currency is required"}`},
+		{query: "currency=usd&date=2026-10-14", status: http.StatusBadRequest, body: `"error":"invalid date`},
+		{query: "currency=xyz&date=14.10.2026", status: http.StatusNotFound, body: `"error":"cannot get currency rate for 'xyz'`},
+	}
+
+	var app = newTestApp(t)
+	app.opts.provider = testProvider
+	var mux = app.newServeMux()
+	for _, tt := range tests {
+		var rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rate?"+tt.query, nil))
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.query, rec.Code, tt.status)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: content type = %s", tt.query, ct)
+		}
+		if body := rec.Body.String(); !strings.Contains(body, tt.body) {
+			t.Errorf("%s: body = %s, want %s", tt.query, body, tt.body)
+		}
+	}
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	set(t, &logger, newLogger(io.Discard, levelError))
+	var advance = clock(t, 10)
+	var cbr = newFakeCBR(t, testDate)
+	failing, failed := failingServer(t, http.StatusInternalServerError, 1000)
+	var failingClient = newTestClient(failing.Client(), failing.URL+"/?date_req=%s")
+
+	var get = func(app *App, path string, status int, body string) {
+		t.Helper()
+		var rec = httptest.NewRecorder()
+		app.newServeMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != status || !strings.Contains(rec.Body.String(), body) {
+			t.Errorf("%s: %d %s, want %d %s", path, rec.Code, rec.Body.String(), status, body)
+		}
+	}
+
+	// healthy: today's rates load from the provider
+	var app = cbr.app(t)
+	get(app, "/healthz", http.StatusOK, `{"status":"ok"}`)
+	get(app, "/readyz", http.StatusOK, `{"status":"ready"}`)
+
+	// the readiness is reused for a while, the provider is not asked
+	app.client = failingClient
+	app.mem = newMemoryRates(defaultMemCacheSize)
+	get(app, "/readyz", http.StatusOK, `{"status":"ready"}`)
+	if n := failed.Load(); n != 0 {
+		t.Errorf("requests within the readiness ttl = %d, want 0", n)
+	}
+
+	// degraded: the provider fails and nothing is cached, the process is
+	// still live
+	var degraded = newTestApp(t)
+	degraded.client = failingClient
+	get(degraded, "/healthz", http.StatusOK, `{"status":"ok"}`)
+	get(degraded, "/readyz", http.StatusServiceUnavailable, `"error":`)
+
+	// degraded provider, ready with today's expired cached rates
+	advance(time.Hour)
+	var stale = reopen(app)
+	stale.opts.cacheTTL = time.Minute
+	failed.Store(0)
+	get(stale, "/readyz", http.StatusOK, `{"status":"ready"}`)
+	if n := failed.Load(); n == 0 {
+		t.Error("expired cached rates were not refreshed from the provider")
+	}
+}
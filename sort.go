@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+const (
+	sortByInput = "input"
+	sortByCode  = "code"
+	sortByRate  = "rate"
+	sortByName  = "name"
+)
+
+var sortOrders = []string{sortByInput, sortByCode, sortByRate}
+
+// validateSort checks the rate rows sort order.
+func validateSort(by string) error {
+	for _, o := range sortOrders {
+		if by == o {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown sort order '%s', expected input, code or rate", by)
+}
+
+// sortRows orders the rate rows of every date: by input keeps the order
+// the currencies were requested in, by code sorts alphabetically and by
+// rate sorts numerically, both ascending.
+func sortRows(rows [][]string, by string) error {
+	var less func(a, b []string) bool
+	switch by {
+	case sortByInput:
+		return nil
+	case sortByCode:
+		less = func(a, b []string) bool { return a[1] < b[1] }
+	case sortByRate:
+		for _, row := range rows {
+			_, err := strconv.ParseFloat(row[2], 64)
+			if err != nil {
+				return fmt.Errorf("cannot sort by rate: invalid rate '%s' for %s", row[2], row[1])
+			}
+		}
+		less = func(a, b []string) bool {
+			x, _ := strconv.ParseFloat(a[2], 64)
+			y, _ := strconv.ParseFloat(b[2], 64)
+			return x < y
+		}
+	default:
+		return validateSort(by)
+	}
+
+	// rows come grouped by date, each group is sorted on its own
+	for start := 0; start < len(rows); {
+		end := start + 1
+		for end < len(rows) && rows[end][0] == rows[start][0] {
+			end++
+		}
+
+		var group = rows[start:end]
+		sort.SliceStable(group, func(i, j int) bool {
+			return less(group[i], group[j])
+		})
+		start = end
+	}
+
+	return nil
+}
+
+// pageRows returns the limit rows starting at offset, every row from
+// offset with a zero limit. An offset past the end leaves no rows.
+func pageRows(rows [][]string, offset, limit int) [][]string {
+	if offset >= len(rows) {
+		return nil
+	}
+
+	rows = rows[offset:]
+	if limit > 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSortRows(t *testing.T) {
+	var rows = func() [][]string {
+		return [][]string{
+			{"14.10.2026", "USD", "92.50"},
+			{"14.10.2026", "EUR", "100.12"},
+			{"14.10.2026", "JPY", "9.75"},
+			{"15.10.2026", "USD", "92.00"},
+			{"15.10.2026", "EUR", "100.50"},
+		}
+	}
+
+	var tests = []struct {
+		by   string
+		want []string
+		err  string
+	}{
+		{
+			by:   sortByInput,
+			want: []string{"14.10.2026 USD", "14.10.2026 EUR", "14.10.2026 JPY", "15.10.2026 USD", "15.10.2026 EUR"},
+		},
+		{
+			by:   sortByCode,
+			want: []string{"14.10.2026 EUR", "14.10.2026 JPY", "14.10.2026 USD", "15.10.2026 EUR", "15.10.2026 USD"},
+		},
+		{
+			// 9.75 < 92.50 < 100.12, lexicographically 100.12 would be first
+			by:   sortByRate,
+			want: []string{"14.10.2026 JPY", "14.10.2026 USD", "14.10.2026 EUR", "15.10.2026 USD", "15.10.2026 EUR"},
+		},
+		{
+			by:  "volume",
+			err: "unknown sort order 'volume'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.by, func(t *testing.T) {
+			var rows = rows()
+			err := sortRows(rows, tt.by)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("sortRows(%s) error = %v, want %q", tt.by, err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("sortRows(%s): %v", tt.by, err)
+			}
+			if got := rowKeys(rows); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortRows(%s) = %q, want %q", tt.by, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortRowsInvalidRate(t *testing.T) {
+	var rows = [][]string{
+		{"14.10.2026", "USD", "92.50"},
+		{"14.10.2026", "EUR", "n/a"},
+	}
+
+	err := sortRows(rows, sortByRate)
+	if err == nil || !strings.Contains(err.Error(), "invalid rate 'n/a' for EUR") {
+		t.Errorf("sortRows() error = %v, want the invalid rate", err)
+	}
+}
+
+func TestPageRows(t *testing.T) {
+	var rows = [][]string{
+		{"14.10.2026", "EUR", "100.12"},
+		{"14.10.2026", "JPY", "0.62"},
+		{"14.10.2026", "USD", "92.50"},
+	}
+
+	var tests = []struct {
+		offset, limit int
+		want          []string
+	}{
+		{want: []string{"14.10.2026 EUR", "14.10.2026 JPY", "14.10.2026 USD"}},
+		{limit: 2, want: []string{"14.10.2026 EUR", "14.10.2026 JPY"}},
+		{offset: 1, want: []string{"14.10.2026 JPY", "14.10.2026 USD"}},
+		{offset: 1, limit: 1, want: []string{"14.10.2026 JPY"}},
+		{offset: 2, limit: 5, want: []string{"14.10.2026 USD"}},
+		// an offset past the end leaves no rows
+		{offset: 3},
+		{offset: 10, limit: 1},
+	}
+
+	for _, tt := range tests {
+		if got := rowKeys(pageRows(rows, tt.offset, tt.limit)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("pageRows(offset %d, limit %d) = %v, want %v", tt.offset, tt.limit, got, tt.want)
+		}
+	}
+
+	// the page of the sorted rows of the fixture
+	var cbr = newFakeCBR(t, testDate)
+	code, out := runCLI(t, "--url-template", cbr.template(), "--all", "--sort", "code", "--offset", "1", "--limit", "1", "--date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	if want := "14.10.2026\tJPY\t0.62\tRUB\t100\n"; out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+	if code, out := runCLI(t, "--url-template", cbr.template(), "--all", "--offset", "3", "--date", "14.10.2026"); code != exitOK || out != "" {
+		t.Errorf("offset past the end: exit code = %d, output = %q, want no rows", code, out)
+	}
+	if code, _ := runCLI(t, "--url-template", cbr.template(), "--all", "--limit", "-1", "--date", "14.10.2026"); code != exitUsage {
+		t.Errorf("negative limit exit code = %d, want %d", code, exitUsage)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var conversionHeader = []string{"from", "to", "amount", "value"}
+
+// summaryRows returns the --summary footer of the rate rows: the minimum,
+// maximum and average rate formatted with the options, named in the code
+// column. The other columns are blank.
+func (o rowOptions) summaryRows(rows [][]string) (summary [][]string, err error) {
+	var header = o.header()
+	var codeCol, rateCol = columnIndex(header, "code"), columnIndex(header, "rate")
+	if len(rows) == 0 || codeCol < 0 || rateCol < 0 {
+		return
+	}
+
+	var min, max, sum float64
+	for i, row := range rows {
+		val, err := strconv.ParseFloat(row[rateCol], 64)
+		if err != nil {
+			return nil, fmt.Errorf("summary of rate '%s': %w", row[rateCol], err)
+		}
+
+		if i == 0 || val < min {
+			min = val
+		}
+		if i == 0 || val > max {
+			max = val
+		}
+		sum += val
+	}
+
+	for _, stat := range []struct {
+		name string
+		val  float64
+	}{
+		{"min", min},
+		{"max", max},
+		{"average", sum / float64(len(rows))},
+	} {
+		var row = make([]string, len(header))
+		row[codeCol] = stat.name
+		row[rateCol] = o.format(stat.val)
+		summary = append(summary, row)
+	}
+
+	return
+}
+
+// writeSummarized writes the rate rows followed by their summary rows when
+// summary is set.
+func (a *App) writeSummarized(w io.Writer, format string, rows [][]string, summary bool) error {
+	if !summary {
+		return a.writeRows(w, format, rows)
+	}
+
+	footer, err := a.opts.rows.summaryRows(rows)
+	if err != nil {
+		return err
+	}
+	return a.writeRows(w, format, append(rows[:len(rows):len(rows)], footer...))
+}
+
+func columnIndex(header []string, name string) int {
+	for i, col := range header {
+		if col == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// conversionRows converts the amount of every currency of from to the
+// currency to, a row each. With summary a final total row sums the
+// converted values.
+func (a *App) conversionRows(ctx context.Context, from []string, to string, amount float64, t time.Time, skipCache bool, summary bool) (rows [][]string, err error) {
+	var o = a.opts.rows
+	var total float64
+	for _, code := range from {
+		val, err := a.convert(ctx, code, to, amount, t, skipCache)
+		if err != nil {
+			return nil, err
+		}
+
+		total += val
+		rows = append(rows, []string{
+			o.caseCode(strings.ToUpper(code)),
+			o.caseCode(strings.ToUpper(to)),
+			strconv.FormatFloat(amount, 'f', -1, 64),
+			o.format(val),
+		})
+	}
+
+	if summary {
+		rows = append(rows, []string{"total", o.caseCode(strings.ToUpper(to)), "", o.format(total)})
+	}
+
+	return
+}
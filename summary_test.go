@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestConversionSummary(t *testing.T) {
+	var app = newTestApp(t)
+	app.opts.provider = testProvider
+
+	// 925.012 + 6.23456
+	rows, err := app.conversionRows(context.Background(), []string{"usd", "jpy"}, "rub", 10, testDate, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = [][]string{
+		{"USD", "RUB", "10", "925.01"},
+		{"JPY", "RUB", "10", "6.23"},
+		{"total", "RUB", "", "931.25"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+
+	rows, err = app.conversionRows(context.Background(), []string{"usd", "jpy"}, "rub", 10, testDate, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rows, want[:2]) {
+		t.Errorf("rows without the summary = %v, want %v", rows, want[:2])
+	}
+}
+
+func TestRateSummary(t *testing.T) {
+	var cbr = newFakeCBR(t, testDate)
+
+	// the average of 92.50, 100.12 and 0.62
+	code, out := runCLI(t, "--url-template", cbr.template(), "--summary", "--currency", "usd,eur,jpy", "--date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	var want = "14.10.2026\tUSD\t92.50\tRUB\t1\n" +
+		"14.10.2026\tEUR\t100.12\tRUB\t1\n" +
+		"14.10.2026\tJPY\t0.62\tRUB\t100\n" +
+		"\tmin\t0.62\t\t\n" +
+		"\tmax\t100.12\t\t\n" +
+		"\taverage\t64.41\t\t\n"
+	if out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+
+	// the summary needs the code and rate columns
+	code, out = runCLI(t, "--url-template", cbr.template(), "--summary", "--fields", "rate,code", "--currency", "usd,jpy", "--date", "14.10.2026")
+	if want := "92.50\tUSD\n0.62\tJPY\n0.62\tmin\n92.50\tmax\n46.56\taverage\n"; code != exitOK || out != want {
+		t.Errorf("selected fields: exit code = %d, output = %q, want %q", code, out, want)
+	}
+	for _, fields := range []string{"code,name", "date,rate"} {
+		if code, _ := runCLI(t, "--url-template", cbr.template(), "--summary", "--fields", fields, "--currency", "usd", "--date", "14.10.2026"); code != exitUsage {
+			t.Errorf("--fields %s: exit code = %d, want %d", fields, code, exitUsage)
+		}
+	}
+
+	rows, err := rowOptions{precision: 2}.summaryRows(nil)
+	if err != nil || rows != nil {
+		t.Errorf("summary of no rows = %v, %v, want none", rows, err)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Build information, set with
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=...".
+var (
+	version   = "dev"
+	commit    = "dev"
+	buildDate = "dev"
+)
+
+func writeVersion(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "currency %s\ncommit: %s\nbuilt: %s\n", version, commit, buildDate)
+	return err
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	set(t, &version, "1.2.3")
+	set(t, &commit, "abc1234")
+	set(t, &buildDate, "2026-10-14T08:00:00Z")
+
+	var want = "currency 1.2.3\ncommit: abc1234\nbuilt: 2026-10-14T08:00:00Z\n"
+	for _, args := range [][]string{{"--version"}, {versionCommand}} {
+		var code int
+		var out = capture(t, &os.Stdout, func() { code, _ = runCLI(t, args...) })
+		if code != exitOK {
+			t.Errorf("%v: exit code = %d, want %d", args, code, exitOK)
+		}
+		if out != want {
+			t.Errorf("%v: output = %q, want %q", args, out, want)
+		}
+	}
+}
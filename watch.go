@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\033[H\033[2J"
+
+// isTerminal reports whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	if n, ok := w.(nopWriteCloser); ok {
+		w = n.Writer
+	}
+
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// watch calls refresh every interval until ctx is done, clearing the
+// screen first when w is a terminal unless the refreshes stream. Failed
+// refreshes are logged and retried on the next tick.
+func (a *App) watch(ctx context.Context, w io.Writer, interval time.Duration, stream bool, refresh func() error) error {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if isTerminal(w) && !stream {
+			_, err := fmt.Fprint(w, clearScreen)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := refresh()
+		if err != nil {
+			logger.Errorf("refresh failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		// reload the rates from the cache or the provider on every tick
+		a.mem.reset()
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	var logs bytes.Buffer
+	set(t, &logger, newLogger(&logs, levelError))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the second tick fails, the third one ends the watch
+	var app = newTestApp(t)
+	app.opts.provider = testProvider
+	var buf bytes.Buffer
+	var ticks int
+	err := app.watch(ctx, &buf, time.Millisecond, false, func() error {
+		ticks++
+		switch ticks {
+		case 2:
+			return errors.New("provider is down")
+		case 3:
+			cancel()
+		}
+
+		rows, err := app.collectRows(ctx, []time.Time{testDate}, []string{"usd"}, false, false, 1)
+		if err != nil {
+			return err
+		}
+		return app.writeRows(&buf, tsvFormat, rows)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ticks != 3 {
+		t.Errorf("refreshed %d times, want 3", ticks)
+	}
+	// a buffer is not a terminal, the screen is not cleared
+	if want := "14.10.2026\tUSD\t92.50\tRUB\t1\n14.10.2026\tUSD\t92.50\tRUB\t1\n"; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+	if !strings.Contains(logs.String(), "refresh failed: provider is down") {
+		t.Errorf("log = %q, want the failed refresh", logs.String())
+	}
+}
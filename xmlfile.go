@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"currency/currency"
+)
+
+const xmlFileProviderName = "xml-file"
+
+// xmlFileProvider reads the rates of a day from a saved CBR XML file
+// instead of the network. The file holds a single day, it is returned for
+// whatever date is requested.
+type xmlFileProvider struct {
+	path string
+}
+
+func (xmlFileProvider) Name() string {
+	return xmlFileProviderName
+}
+
+func (xmlFileProvider) Base() string {
+	return rubCurrency
+}
+
+func (p xmlFileProvider) URL(client *currency.Client, t time.Time) string {
+	return p.path
+}
+
+// Rates decodes the file, it has no validators.
+func (p xmlFileProvider) Rates(ctx context.Context, client *currency.Client, t time.Time, fallback bool, cond currency.Validators) (valutes []*Valute, date time.Time, _ currency.Validators, err error) {
+	body, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, date, currency.Validators{}, fmt.Errorf("cannot read rates from %s: %w", p.path, err)
+	}
+
+	v, err := currency.Decode(body)
+	if err != nil {
+		return nil, date, currency.Validators{}, fmt.Errorf("cannot decode rates from %s: %w", p.path, err)
+	}
+
+	date, err = publishedDate(v, t)
+	if err != nil {
+		return nil, date, currency.Validators{}, err
+	}
+	return cbrValutes(v), date, currency.Validators{}, nil
+}
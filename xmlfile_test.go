@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestXMLFileNotCached(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "cache")
+
+	code, out := runCLI(t, "--cache-path", path, "--xml-file", "testdata/daily.xml", "--currency", "usd,jpy", "--date", "14.10.2026")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	if want := "14.10.2026\tUSD\t92.50\tRUB\t1\n14.10.2026\tJPY\t0.62\tRUB\t100\n"; out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(cacheBucket)); b != nil {
+			return b.ForEach(func(k, v []byte) error {
+				t.Errorf("cache entry %s written for --xml-file", k)
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}